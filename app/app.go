@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -27,6 +28,14 @@ import (
 
 type App struct {
 	fyne.App
+
+	// Cancelled once the main window is closed.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Cancels the update which is currently running, if any.
+	cancelUpdate context.CancelFunc
+
 	settings        *resource.Settings
 	rejectedPatches *patch.RejectionList
 
@@ -38,11 +47,13 @@ type App struct {
 	settingsWindow fyne.Window
 	patchWindow    fyne.Window
 	infoWindow     fyne.Window
+	historyWindow  fyne.Window
 
 	serverList *nlwidgets.ServerList
 
 	playButton           *widget.Button
 	refreshUpdatesButton *widget.Button
+	patchHistoryButton   *widget.Button
 	progressBar          *nlwidgets.BinaryProgressBar
 
 	serverNameBinding binding.String
@@ -60,18 +71,29 @@ type App struct {
 func New(settings *resource.Settings, servers resource.ServerList, rejectedPatches *patch.RejectionList) App {
 	a := App{}
 	a.App = app.New()
+	a.ctx, a.cancel = context.WithCancel(context.Background())
 
 	a.settings = settings
 	a.rejectedPatches = rejectedPatches
 
 	a.client = client.NewStandardClient()
 
+	err := server.SetTransportConfig(settings.TransportConfig())
+	if err != nil {
+		log.Printf("Invalid network settings: %v", err)
+	}
+
 	resources, err := resource.ClientResources()
 	if err != nil {
 		log.Panicf("Could not create client cache database: %v", err)
 	}
 	a.clientResources = resources
 
+	// Restore any resources left behind by a transfer which was interrupted when the launcher last closed
+	if err := client.Rollback(settings.Client.Directory, resources); err != nil {
+		log.Printf("Could not recover interrupted transfer: %v", err)
+	}
+
 	a.main = a.NewWindow(fmt.Sprintf("Nimbus Launcher (%v)", version.Get().Name()))
 	a.main.SetFixedSize(true)
 	a.main.Resize(fyne.NewSize(800, 300))
@@ -98,6 +120,8 @@ func New(settings *resource.Settings, servers resource.ServerList, rejectedPatch
 	a.LoadContent()
 
 	a.main.SetOnClosed(func() {
+		a.cancel()
+
 		err := a.clientResources.Close()
 		if err != nil {
 			log.Printf("could not properly close clientCache: %v", err)
@@ -118,6 +142,13 @@ func (app *App) InitializeGlobalWidgets(servers resource.ServerList) {
 		},
 	)
 
+	app.patchHistoryButton = widget.NewButtonWithIcon(
+		"", theme.HistoryIcon(),
+		func() {
+			app.ShowPatchHistory(app.CurrentServer())
+		},
+	)
+
 	app.playButton = widget.NewButtonWithIcon(
 		"Play", theme.MediaPlayIcon(),
 		app.PressPlay,
@@ -145,8 +176,10 @@ func (app *App) BindServerInfo(serv *server.Server) {
 
 	if len(serv.PatchProtocol) > 0 {
 		app.refreshUpdatesButton.Show()
+		app.patchHistoryButton.Show()
 	} else {
 		app.refreshUpdatesButton.Hide()
+		app.patchHistoryButton.Hide()
 	}
 }
 
@@ -164,7 +197,7 @@ func (app *App) OnServerChanged(server *server.Server) {
 		log.Printf("save settings error: %v\n", err)
 	}
 
-	if app.IsReady() && app.settings.CheckPatchesAutomatically {
+	if app.IsReady() && app.settings.CheckPatchesAutomatically && (server == nil || !server.PatchPinned) {
 		app.CheckForUpdates(server)
 	} else if server != nil {
 		if server.CheckingUpdates() {
@@ -173,6 +206,7 @@ func (app *App) OnServerChanged(server *server.Server) {
 			app.SetUpdateState()
 		} else {
 			app.SetNormalState()
+			app.ShowSavedPatchStatus(server)
 		}
 	}
 }
@@ -232,13 +266,13 @@ func (app *App) TransferCachedClientResources() error {
 
 func (app *App) TransferPatchResources(server *server.Server) error {
 	log.Println("Transfer patch resources...")
-	patch, err := server.GetPatch(server.CurrentPatch)
+	patch, err := server.GetPatch(app.ctx, server.CurrentPatch)
 	if err != nil {
 		return err
 	}
 
 	app.progressBar.ShowIndefinite()
-	err = patch.TransferResourcesWithDependencies(app.settings.Client.Directory, app.clientResources, server)
+	err = patch.TransferResourcesWithDependencies(app.ctx, app.settings.Client.Directory, app.clientResources, server, newPatchProgress(app.progressBar))
 	if err != nil {
 		return err
 	}
@@ -270,6 +304,13 @@ func (app *App) PressPlay() {
 
 	log.Printf("Selected server: %s\n", server.Name)
 
+	if pending := app.knownSummary(server).PendingMandatory(server.CurrentPatch); len(pending) > 0 {
+		log.Printf("Mandatory patch versions are not installed: %v", pending)
+		dialog.ShowInformation("Update Required", fmt.Sprintf("Patch version \"%s\" is mandatory and must be installed before playing.", pending[0]), app.main)
+		app.SetUpdateState()
+		return
+	}
+
 	err := app.TransferCachedClientResources()
 	if err != nil {
 		log.Println(err)
@@ -291,7 +332,9 @@ func (app *App) PressPlay() {
 		err := app.TransferPatchResources(server)
 		if err != nil {
 			log.Println(err)
-			dialog.ShowError(fmt.Errorf("patch resources may be incorrect when running: %v", err), app.main)
+			dialog.ShowError(fmt.Errorf("could not transfer patch resources; client resources have been restored: %v", err), app.main)
+			app.SetNormalState()
+			return
 		}
 	}
 
@@ -328,6 +371,22 @@ func (app *App) PressUpdate() {
 	app.Update(app.CurrentServer())
 }
 
+func (app *App) PressCancel() {
+	if app.cancelUpdate == nil {
+		return
+	}
+
+	log.Println("Cancelling update...")
+	app.cancelUpdate()
+
+	app.playButton.Disable()
+	app.playButton.SetText("Cancelling")
+
+	if app.patchWindow != nil {
+		app.patchWindow.Close()
+	}
+}
+
 func (app *App) ShowSettings() {
 	if app.settingsWindow != nil {
 		app.settingsWindow.RequestFocus()
@@ -359,13 +418,13 @@ func (app *App) ShowSettings() {
 
 }
 
-func (app *App) ShowPatch(patch patch.Patch, onConfirmCancel func(nlwindows.PatchAcceptState)) {
+func (app *App) ShowPatch(patch patch.Patch, plan *patch.Plan, metadata patch.Metadata, onConfirmCancel func(nlwindows.PatchAcceptState)) {
 	if app.patchWindow != nil {
 		app.patchWindow.RequestFocus()
 		return
 	}
 
-	app.patchWindow = nlwindows.NewPatchReviewWindow(app, patch, onConfirmCancel)
+	app.patchWindow = nlwindows.NewPatchReviewWindow(app, patch, plan, metadata, onConfirmCancel)
 	app.patchWindow.SetOnClosed(func() {
 		app.patchWindow = nil
 		onConfirmCancel(nlwindows.PatchCancel)
@@ -375,6 +434,59 @@ func (app *App) ShowPatch(patch patch.Patch, onConfirmCancel func(nlwindows.Patc
 	app.patchWindow.Show()
 }
 
+// Shows the versions available from the server's patch server, allowing any of the versions to be installed.
+func (app *App) ShowPatchHistory(serv *server.Server) {
+	if serv == nil {
+		return
+	}
+
+	if app.historyWindow != nil {
+		app.historyWindow.RequestFocus()
+		return
+	}
+
+	// The window is created before the summary is fetched, so that pressing the history button again
+	// focuses the window instead of fetching the summary twice
+	ctx, cancel := context.WithCancel(app.ctx)
+
+	window := nlwindows.NewPatchHistoryWindow(app, serv)
+	window.SetOnClosed(func() {
+		cancel()
+		app.historyWindow = nil
+	})
+
+	app.historyWindow = window
+	window.CenterOnScreen()
+	window.Show()
+
+	go func() {
+		log.Printf("Fetching patch history for \"%s\"", serv.Name)
+		summary, err := serv.GetPatchesSummary(ctx)
+		if errors.Is(err, patch.ErrPatchesUnavailable) {
+			if saved, ok := serv.SavedPatchesSummary(); ok {
+				log.Printf("Patch server is unavailable; Using saved summary for \"%s\"", serv.Name)
+				summary, err = saved, nil
+			}
+		}
+
+		// The window was closed while the summary was being fetched
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Printf("Patch server error: %v\n", err)
+			window.Close()
+			dialog.ShowError(fmt.Errorf("could not fetch patch history: %w", err), app.main)
+			return
+		}
+
+		nlwindows.LoadPatchHistoryContainer(window, serv, summary, app.rejectedPatches, func(version string) {
+			app.Install(serv, version, version != summary.CurrentVersion)
+		})
+	}()
+}
+
 func (app *App) ShowInfo() {
 	if app.infoWindow != nil {
 		app.infoWindow.RequestFocus()
@@ -390,11 +502,26 @@ func (app *App) ShowInfo() {
 	app.infoWindow.Show()
 }
 
-func (app *App) RunUpdate(server *server.Server, patch patch.Patch) {
+func (app *App) Downloader() *patch.Downloader {
+	return patch.NewDownloader(app.settings.MaxConcurrentDownloads)
+}
+
+// Runs the patch's update, and then sets the patch as the server's current patch. If pinned is true,
+// updates for the server are no longer checked for automatically.
+func (app *App) RunUpdate(ctx context.Context, server *server.Server, patch patch.Patch, pinned bool) {
 	defer app.serverList.RemoveAsUpdating(server)
 
+	if len(server.Mirrors()) > 1 {
+		server.CheckMirrors(ctx)
+	}
+
 	log.Println("Starting update...")
-	err := patch.UpdateResources(server, app.rejectedPatches)
+	err := patch.UpdateResources(ctx, server, app.rejectedPatches, app.Downloader(), newPatchProgress(app.progressBar))
+	if errors.Is(err, context.Canceled) {
+		log.Println("Update cancelled.")
+		return
+	}
+
 	if err != nil {
 		log.Println(err)
 		dialog.ShowError(err, app.main)
@@ -405,28 +532,57 @@ func (app *App) RunUpdate(server *server.Server, patch patch.Patch) {
 	app.serverList.Refresh()
 
 	server.CurrentPatch = patch.Version()
+	server.PatchPinned = pinned
 	app.serverList.Save()
 }
 
-func (app *App) Update(serv *server.Server) {
-	app.SetUpdatingState()
-
-	app.serverList.MarkAsUpdating(serv)
+// Returns the server's patches summary, or its saved summary if the summary has not been received.
+func (app *App) knownSummary(serv *server.Server) patch.Summary {
+	summary, ok := serv.PatchesSummary()
+	if !ok {
+		summary, _ = serv.SavedPatchesSummary()
+	}
+	return summary
+}
 
+// Installs the current version from the server's patches summary, or from its saved summary if the
+// summary has not been received.
+func (app *App) Update(serv *server.Server) {
 	versions, ok := serv.PatchesSummary()
+	if !ok {
+		versions, ok = serv.SavedPatchesSummary()
+	}
+
 	if !ok {
 		log.Printf("Patches missing for \"%s\"\n", serv.Name)
 		return
 	}
 
+	app.Install(serv, versions.CurrentVersion, false)
+}
+
+// Installs the version, along with its dependencies, and sets it as the server's current patch.
+//
+// If pinned is true, the server is no longer checked for updates automatically, so that versions
+// other than the server's current version remain installed until an update is requested.
+func (app *App) Install(serv *server.Server, version string, pinned bool) {
+	app.SetUpdatingState()
+
+	app.serverList.MarkAsUpdating(serv)
+
+	ctx, cancel := context.WithCancel(app.ctx)
+	app.cancelUpdate = cancel
+
 	go func(version string, serv *server.Server) {
 		defer serv.SetState(server.Normal)
 		log.Printf("Getting patch \"%s\" for %s\n", version, serv.Name)
 
-		p, err := serv.GetPatch(version)
+		p, err := serv.GetPatch(ctx, version)
 		if err != nil {
+			cancel()
+
 			log.Printf("Patch error: %v", err)
-			if !errors.Is(err, patch.ErrPatchesUnavailable) {
+			if !errors.Is(err, patch.ErrPatchesUnavailable) && !errors.Is(err, context.Canceled) {
 				dialog.ShowError(err, app.main)
 			}
 
@@ -436,14 +592,42 @@ func (app *App) Update(serv *server.Server) {
 
 		log.Printf("Patch received: %s", p.Summary())
 
+		problems := patch.Lint(p, patch.LintOptions{Read: patch.RemoteReader(ctx, serv)})
+		if err := patch.ProblemsError(problems); err != nil {
+			cancel()
+
+			log.Printf("Patch lint error: %v", err)
+			if !errors.Is(ctx.Err(), context.Canceled) {
+				dialog.ShowError(fmt.Errorf("invalid patch \"%s\": %w", p.Version(), err), app.main)
+			}
+
+			app.SetNormalState()
+			return
+		}
+
 		if !app.settings.ReviewPatchBeforeUpdate {
-			app.RunUpdate(serv, p)
+			app.RunUpdate(ctx, serv, p, pinned)
+			cancel()
 			app.SetNormalState()
 			return
 		}
 
-		app.ShowPatch(p, func(state nlwindows.PatchAcceptState) {
+		plan, err := patch.MakePlan(ctx, serv, p, app.settings.Client.Directory, app.clientResources, app.Downloader())
+		if err != nil {
+			cancel()
+
+			log.Printf("Patch plan error: %v", err)
+			if !errors.Is(err, context.Canceled) {
+				dialog.ShowError(fmt.Errorf("could not plan patch: %w", err), app.main)
+			}
+
+			app.SetNormalState()
+			return
+		}
+
+		app.ShowPatch(p, plan, patch.MetadataOf(app.knownSummary(serv), p), func(state nlwindows.PatchAcceptState) {
 			defer app.SetNormalState()
+			defer cancel()
 
 			if state == nlwindows.PatchCancel {
 				return
@@ -459,9 +643,9 @@ func (app *App) Update(serv *server.Server) {
 				return
 			}
 
-			app.RunUpdate(serv, p)
+			app.RunUpdate(ctx, serv, p, pinned)
 		})
-	}(versions.CurrentVersion, serv)
+	}(version, serv)
 }
 
 func (app *App) CheckForUpdates(serv *server.Server) {
@@ -469,7 +653,7 @@ func (app *App) CheckForUpdates(serv *server.Server) {
 		return
 	}
 
-	if len(serv.Config.PatchServerIP) == 0 || !app.clientErrorIcon.Hidden {
+	if !serv.HasPatchServer() || !app.clientErrorIcon.Hidden {
 		return
 	}
 
@@ -483,7 +667,14 @@ func (app *App) CheckForUpdates(serv *server.Server) {
 	go func(serv *server.Server) {
 		log.Printf("Checking for updates for \"%s\"; Current version: \"%s\"\n", serv.Name, serv.CurrentPatch)
 
-		patches, err := serv.GetPatchesSummary()
+		patches, err := serv.GetPatchesSummary(app.ctx)
+		if err == patch.ErrPatchesUnavailable {
+			if saved, ok := serv.SavedPatchesSummary(); ok {
+				log.Printf("Patch server is unavailable; Using saved summary for \"%s\"", serv.Name)
+				patches, err = saved, nil
+			}
+		}
+
 		if err != nil {
 			log.Printf("Patch server error: %v\n", err)
 			if err != patch.ErrPatchesUnavailable && err != patch.ErrPatchesUnsupported {
@@ -499,26 +690,11 @@ func (app *App) CheckForUpdates(serv *server.Server) {
 			return
 		}
 
-		if serv.CurrentPatch == patches.CurrentVersion {
-			log.Println("Server is already latest version.")
-			serv.SetPatchesSummary(patches)
-			serv.SetState(server.Normal)
-			app.SetNormalState()
-			return
-		}
-
-		if err := patch.ValidateVersionName(patches.CurrentVersion); err != nil {
-			log.Println(err)
-			serv.SetPatchesSummary(patches)
-			serv.SetState(server.Normal)
-			app.SetNormalState()
-			return
-		}
-
-		log.Printf("Patch version \"%s\" is available\n", patches.CurrentVersion)
+		if !app.isUpdate(serv, patches) {
+			if !app.rejectedPatches.IsRejected(serv, patches.CurrentVersion) {
+				serv.SetPatchesSummary(patches)
+			}
 
-		if app.rejectedPatches.IsRejected(serv, patches.CurrentVersion) {
-			log.Printf("Patch version \"%s\" is rejected; Aborting update sequence.\n", patches.CurrentVersion)
 			serv.SetState(server.Normal)
 			app.SetNormalState()
 			return
@@ -530,6 +706,51 @@ func (app *App) CheckForUpdates(serv *server.Server) {
 	}(serv)
 }
 
+// Returns true if the current version of the summary should be installed on the server.
+func (app *App) isUpdate(serv *server.Server, patches patch.Summary) bool {
+	if serv.CurrentPatch == patches.CurrentVersion {
+		log.Println("Server is already latest version.")
+		return false
+	}
+
+	if err := patch.ValidateVersionName(patches.CurrentVersion); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	log.Printf("Patch version \"%s\" is available\n", patches.CurrentVersion)
+
+	if app.rejectedPatches.IsRejected(serv, patches.CurrentVersion) {
+		log.Printf("Patch version \"%s\" is rejected; Aborting update sequence.\n", patches.CurrentVersion)
+		return false
+	}
+
+	return true
+}
+
+// Shows the last known patch status of the server from its saved summary, without requesting
+// the summary from the patch server.
+func (app *App) ShowSavedPatchStatus(serv *server.Server) {
+	if serv == nil || serv.PatchPinned || !serv.HasPatchServer() || !app.clientErrorIcon.Hidden {
+		return
+	}
+
+	if _, ok := serv.PatchesSummary(); ok {
+		return
+	}
+
+	patches, ok := serv.SavedPatchesSummary()
+	if !ok {
+		return
+	}
+
+	log.Printf("Using saved summary for \"%s\"", serv.Name)
+	if app.isUpdate(serv, patches) {
+		serv.SetState(server.PendingUpdate)
+		app.SetUpdateState()
+	}
+}
+
 func (app *App) IsReady() bool {
 	return app.playButton != nil
 }
@@ -572,8 +793,10 @@ func (app *App) CheckPrerequisites() {
 func (app *App) Start() {
 	app.CheckClient()
 
-	if app.settings.CheckPatchesAutomatically {
-		app.CheckForUpdates(app.CurrentServer())
+	if server := app.CurrentServer(); app.settings.CheckPatchesAutomatically && server != nil && !server.PatchPinned {
+		app.CheckForUpdates(server)
+	} else {
+		app.ShowSavedPatchStatus(server)
 	}
 
 	if !app.settings.MeetsPrerequisites {
@@ -1,7 +1,10 @@
 package app
 
 import (
+	"fmt"
+	"log"
 	"path/filepath"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -9,6 +12,9 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/I-Am-Dench/nimbus-launcher/app/nlwidgets"
+	"github.com/I-Am-Dench/nimbus-launcher/resource"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
 )
 
 func (app *App) LoadContent() {
@@ -46,7 +52,7 @@ func (app *App) LoadContent() {
 		container.NewVBox(
 			AddEllipsis(widget.NewLabelWithData(app.signupBinding)),
 			AddEllipsis(widget.NewLabelWithData(app.signinBinding)),
-			container.NewBorder(nil, nil, nil, app.refreshUpdatesButton),
+			container.NewBorder(nil, nil, nil, container.NewHBox(app.patchHistoryButton, app.refreshUpdatesButton)),
 		),
 	)
 
@@ -114,6 +120,19 @@ func (app *App) LauncherSettings(window fyne.Window) *fyne.Container {
 	reviewPatchBeforeUpdate := widget.NewCheck("", func(b bool) {})
 	reviewPatchBeforeUpdate.Checked = app.settings.ReviewPatchBeforeUpdate
 
+	maxConcurrentDownloads := nlwidgets.NewIntegerEntry(int64(app.settings.MaxConcurrentDownloads))
+
+	networkHeading := canvas.NewText("Network", theme.ForegroundColor())
+	networkHeading.TextSize = 16
+
+	connectTimeout := nlwidgets.NewIntegerEntry(int64(app.settings.Network.ConnectTimeout))
+	responseTimeout := nlwidgets.NewIntegerEntry(int64(app.settings.Network.ResponseTimeout))
+	retries := nlwidgets.NewIntegerEntry(int64(app.settings.Network.Retries))
+
+	proxy := widget.NewEntry()
+	proxy.PlaceHolder = "(Optional) http://proxy:8080; Uses HTTP_PROXY by default"
+	proxy.SetText(app.settings.Network.Proxy)
+
 	clientDirectory := widget.NewEntry()
 	clientDirectoryButton := widget.NewButtonWithIcon(
 		"", theme.FolderOpenIcon(), func() {
@@ -146,17 +165,34 @@ func (app *App) LauncherSettings(window fyne.Window) *fyne.Container {
 	// environmentVariables := widget.NewEntry()
 	// environmentVariables.PlaceHolder = "Separated by ;"
 
+	storageHeading := canvas.NewText("Storage", theme.ForegroundColor())
+	storageHeading.TextSize = 16
+
+	storageSummary, storageContainer := app.StorageSettings(window)
+
 	saveButton := widget.NewButton("Save", func() {
 		app.settings.CloseOnPlay = closeOnPlay.Checked
 		app.settings.CheckPatchesAutomatically = checkPatchesAutomatically.Checked
 		app.settings.ReviewPatchBeforeUpdate = reviewPatchBeforeUpdate.Checked
+		app.settings.MaxConcurrentDownloads = int(maxConcurrentDownloads.Value())
+		app.settings.Network.ConnectTimeout = int(connectTimeout.Value())
+		app.settings.Network.ResponseTimeout = int(responseTimeout.Value())
+		app.settings.Network.Retries = int(retries.Value())
+		app.settings.Network.Proxy = strings.TrimSpace(proxy.Text)
+		app.settings.Adjust()
+
+		err := server.SetTransportConfig(app.settings.TransportConfig())
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
 
 		app.settings.Client.Directory = clientDirectory.Text
 		app.settings.Client.Name = clientName.Text
 		// app.settings.Client.RunCommand = runCommand.Text
 		// app.settings.Client.EnvironmentVariables = environmentVariables.Text
 
-		err := app.settings.Save()
+		err = app.settings.Save()
 		if err != nil {
 			dialog.ShowError(err, window)
 		} else {
@@ -178,6 +214,7 @@ func (app *App) LauncherSettings(window fyne.Window) *fyne.Container {
 						widget.NewFormItem("Close Launcher When Played", closeOnPlay),
 						widget.NewFormItem("Check Patches Automatically", checkPatchesAutomatically),
 						widget.NewFormItem("Review Patch Before Update", reviewPatchBeforeUpdate),
+						widget.NewFormItem("Concurrent Downloads", maxConcurrentDownloads),
 					),
 					widget.NewSeparator(),
 					clientHeading,
@@ -187,8 +224,106 @@ func (app *App) LauncherSettings(window fyne.Window) *fyne.Container {
 						// widget.NewFormItem("Run Command", runCommand),
 						// widget.NewFormItem("EnvironmentVariables", environmentVariables),
 					),
+					widget.NewSeparator(),
+					networkHeading,
+					widget.NewForm(
+						widget.NewFormItem("Connect Timeout (s)", connectTimeout),
+						widget.NewFormItem("Response Timeout (s)", responseTimeout),
+						widget.NewFormItem("Retries", retries),
+						widget.NewFormItem("Proxy", proxy),
+					),
+					widget.NewSeparator(),
+					storageHeading,
+					widget.NewForm(
+						widget.NewFormItem("Patches", storageContainer),
+					),
+					storageSummary,
 				),
 			),
 		),
 	)
 }
+
+func describeGarbage(garbage resource.Garbage) string {
+	if garbage.Empty() {
+		return "Nothing to clean up."
+	}
+
+	return fmt.Sprintf(
+		"%s reclaimable: %d stale version(s); %d removed server(s); %d orphaned rejection(s)",
+		nlwidgets.FormatBytes(garbage.Size), len(garbage.StaleVersions), len(garbage.OrphanedServers), len(garbage.OrphanedRejections),
+	)
+}
+
+// Returns a label describing the reclaimable space within the patches directory, and the buttons which
+// scan and clean up the patches directory. The patches directory is scanned immediately.
+func (app *App) StorageSettings(window fyne.Window) (*widget.Label, *fyne.Container) {
+	summary := widget.NewLabel("")
+	summary.Wrapping = fyne.TextWrapWord
+
+	garbage := resource.Garbage{}
+
+	var scan, cleanUp *widget.Button
+
+	runScan := func() {
+		scan.Disable()
+		cleanUp.Disable()
+		summary.SetText("Scanning patches...")
+
+		go func() {
+			defer scan.Enable()
+
+			var err error
+			garbage, err = resource.FindGarbage(app.ctx, app.serverList.Servers(), app.rejectedPatches)
+			if err != nil {
+				log.Printf("Storage scan error: %v", err)
+				summary.SetText(fmt.Sprintf("Could not scan patches: %v", err))
+				return
+			}
+
+			summary.SetText(describeGarbage(garbage))
+			if !garbage.Empty() {
+				cleanUp.Enable()
+			}
+		}()
+	}
+
+	scan = widget.NewButtonWithIcon("Scan", theme.ViewRefreshIcon(), runScan)
+
+	cleanUp = widget.NewButtonWithIcon("Clean Up", theme.DeleteIcon(), func() {
+		dialog.ShowConfirm("Clean Up Patches", describeGarbage(garbage)+"\n\nRemove these patches?", func(b bool) {
+			if !b {
+				return
+			}
+
+			if app.serverList.Updating() {
+				dialog.ShowError(fmt.Errorf("cannot clean up patches while a server is updating"), window)
+				return
+			}
+
+			scan.Disable()
+			cleanUp.Disable()
+			summary.SetText("Cleaning up patches...")
+
+			go func() {
+				// The servers may have changed since the last scan, so only what is still garbage is removed
+				fresh, err := resource.FindGarbage(app.ctx, app.serverList.Servers(), app.rejectedPatches)
+				if err == nil {
+					err = resource.CollectGarbage(fresh, app.rejectedPatches)
+				}
+
+				if err != nil {
+					log.Printf("Storage clean up error: %v", err)
+					dialog.ShowError(err, window)
+				}
+
+				runScan()
+			}()
+		}, window)
+	})
+	cleanUp.Importance = widget.DangerImportance
+
+	runScan()
+
+	return summary, container.NewHBox(scan, cleanUp)
+}
@@ -2,6 +2,8 @@ package forms
 
 import (
 	"fmt"
+	"io"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -12,6 +14,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/I-Am-Dench/nimbus-launcher/ldf"
 	"github.com/I-Am-Dench/nimbus-launcher/resource"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
 	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
 )
 
@@ -23,6 +26,10 @@ type ServerForm struct {
 	title         *widget.Entry
 	patchToken    *widget.Entry
 	patchProtocol *widget.Select
+	publicKey     *widget.Entry
+	ca            *widget.Entry
+	fingerprint   *widget.Entry
+	mirrors       *widget.Entry
 
 	bootForm *BootForm
 }
@@ -45,7 +52,7 @@ func NewServerForm(window fyne.Window, heading string) *ServerForm {
 	form.patchToken = widget.NewPasswordEntry()
 
 	form.patchProtocol = widget.NewSelect(
-		[]string{"(None)", "http", "https"}, func(s string) {
+		[]string{"(None)", "http", "https", server.FileProtocol}, func(s string) {
 			if s == "(None)" {
 				form.patchProtocol.ClearSelected()
 			}
@@ -53,6 +60,21 @@ func NewServerForm(window fyne.Window, heading string) *ServerForm {
 	)
 	form.patchProtocol.PlaceHolder = "(None)"
 
+	form.publicKey = widget.NewEntry()
+	form.publicKey.PlaceHolder = "(Optional) Base64 Ed25519 public key"
+
+	form.ca = widget.NewMultiLineEntry()
+	form.ca.PlaceHolder = "(Optional) PEM encoded certificates"
+	form.ca.SetMinRowsVisible(3)
+	form.ca.ActionItem = widget.NewButtonWithIcon("", theme.FolderOpenIcon(), form.PromptCAFile(window))
+
+	form.fingerprint = widget.NewEntry()
+	form.fingerprint.PlaceHolder = "(Optional) SHA-256 certificate fingerprint"
+
+	form.mirrors = widget.NewMultiLineEntry()
+	form.mirrors.PlaceHolder = "(Optional) Mirror URLs, one per line"
+	form.mirrors.SetMinRowsVisible(2)
+
 	form.bootForm = NewBootForm(window)
 
 	serverXMLOpen := widget.NewButtonWithIcon("", theme.FileIcon(), form.PromptServerXMLFile(window))
@@ -64,6 +86,10 @@ func NewServerForm(window fyne.Window, heading string) *ServerForm {
 			widget.NewFormItem("Name", form.title),
 			widget.NewFormItem("Patch Token", form.patchToken),
 			widget.NewFormItem("Patch Protocol", form.patchProtocol),
+			widget.NewFormItem("Patch Public Key", form.publicKey),
+			widget.NewFormItem("Patch CA", form.ca),
+			widget.NewFormItem("Patch Fingerprint", form.fingerprint),
+			widget.NewFormItem("Patch Mirrors", form.mirrors),
 		),
 		widget.NewSeparator(),
 		bootHeading,
@@ -95,6 +121,10 @@ func (form *ServerForm) PromptServerXMLFile(window fyne.Window) func() {
 			form.title.SetText(server.Name)
 			form.patchToken.SetText(server.Patch.Token)
 			form.patchProtocol.SetSelected(server.Patch.Protocol)
+			form.publicKey.SetText(server.Patch.PublicKey)
+			form.ca.SetText(server.Patch.CA)
+			form.fingerprint.SetText(server.Patch.Fingerprint)
+			form.mirrors.SetText(strings.Join(server.Patch.Mirrors, "\n"))
 
 			bootConfig := ldf.BootConfig{}
 			err = ldf.Unmarshal([]byte(server.Boot.Text), &bootConfig)
@@ -111,6 +141,34 @@ func (form *ServerForm) PromptServerXMLFile(window fyne.Window) func() {
 	}
 }
 
+// Loads the contents of a PEM file into the Patch CA entry.
+func (form *ServerForm) PromptCAFile(window fyne.Window) func() {
+	return func() {
+		dialog := dialog.NewFileOpen(func(uc fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("error when opening certificate file: %v", err), window)
+				return
+			}
+
+			if uc == nil {
+				return
+			}
+			defer uc.Close()
+
+			data, err := io.ReadAll(uc)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("error when reading certificate file: %v", err), window)
+				return
+			}
+
+			form.ca.SetText(string(data))
+		}, window)
+
+		dialog.SetFilter(storage.NewExtensionFileFilter([]string{".pem", ".crt", ".cer"}))
+		dialog.Show()
+	}
+}
+
 func (form *ServerForm) CreateServer() (*server.Server, error) {
 	err := form.Validate()
 	if err != nil {
@@ -118,10 +176,14 @@ func (form *ServerForm) CreateServer() (*server.Server, error) {
 	}
 
 	return resource.CreateServer(server.Config{
-		Name:          form.title.Text,
-		PatchToken:    form.patchToken.Text,
-		PatchProtocol: form.patchProtocol.Selected,
-		Config:        form.bootForm.GetConfig(),
+		Name:             form.title.Text,
+		PatchToken:       form.patchToken.Text,
+		PatchProtocol:    form.patchProtocol.Selected,
+		PatchPublicKey:   strings.TrimSpace(form.publicKey.Text),
+		PatchCA:          strings.TrimSpace(form.ca.Text),
+		PatchFingerprint: strings.TrimSpace(form.fingerprint.Text),
+		PatchMirrors:     form.Mirrors(),
+		Config:           form.bootForm.GetConfig(),
 	})
 }
 
@@ -133,16 +195,24 @@ func (form *ServerForm) UpdateWith(server *server.Server) {
 	form.title.SetText(server.Name)
 	form.patchToken.SetText(server.PatchToken)
 	form.patchProtocol.SetSelected(server.PatchProtocol)
+	form.publicKey.SetText(server.PatchPublicKey)
+	form.ca.SetText(server.PatchCA)
+	form.fingerprint.SetText(server.PatchFingerprint)
+	form.mirrors.SetText(strings.Join(server.PatchMirrors, "\n"))
 
 	form.bootForm.UpdateWith(server.Config)
 }
 
 func (form *ServerForm) Get() *server.Server {
 	return resource.NewServer(server.Config{
-		Name:          form.title.Text,
-		PatchToken:    form.patchToken.Text,
-		PatchProtocol: form.patchProtocol.Selected,
-		Config:        form.bootForm.GetConfig(),
+		Name:             form.title.Text,
+		PatchToken:       form.patchToken.Text,
+		PatchProtocol:    form.patchProtocol.Selected,
+		PatchPublicKey:   strings.TrimSpace(form.publicKey.Text),
+		PatchCA:          strings.TrimSpace(form.ca.Text),
+		PatchFingerprint: strings.TrimSpace(form.fingerprint.Text),
+		PatchMirrors:     form.Mirrors(),
+		Config:           form.bootForm.GetConfig(),
 	})
 }
 
@@ -151,9 +221,44 @@ func (form *ServerForm) Validate() error {
 		return fmt.Errorf("name cannot be empty")
 	}
 
+	if publicKey := strings.TrimSpace(form.publicKey.Text); len(publicKey) > 0 {
+		if _, err := patch.ParsePublicKey(publicKey); err != nil {
+			return err
+		}
+	}
+
+	if ca := strings.TrimSpace(form.ca.Text); len(ca) > 0 {
+		if _, err := server.ParseCertificateAuthority(ca); err != nil {
+			return err
+		}
+	}
+
+	if fingerprint := strings.TrimSpace(form.fingerprint.Text); len(fingerprint) > 0 {
+		if _, err := server.ParseFingerprint(fingerprint); err != nil {
+			return err
+		}
+	}
+
+	for _, mirror := range form.Mirrors() {
+		if _, err := server.ParseMirror(mirror); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// Returns the non-empty lines of the Patch Mirrors entry.
+func (form *ServerForm) Mirrors() []string {
+	mirrors := []string{}
+	for _, line := range strings.Split(form.mirrors.Text, "\n") {
+		if mirror := strings.TrimSpace(line); len(mirror) > 0 {
+			mirrors = append(mirrors, mirror)
+		}
+	}
+	return mirrors
+}
+
 func (form *ServerForm) Container() *fyne.Container {
 	return form.container
 }
@@ -33,3 +33,9 @@ func (set *MuxSet[K]) Has(item K) bool {
 	_, ok := set.data[item]
 	return ok
 }
+
+func (set *MuxSet[K]) Len() int {
+	set.mux.Lock()
+	defer set.mux.Unlock()
+	return len(set.data)
+}
@@ -129,3 +129,12 @@ func (list *ServerList) RemoveAsUpdating(server *server.Server) {
 func (list *ServerList) Save() error {
 	return list.servers.SaveInfos()
 }
+
+// Returns true if any server is currently updating.
+func (list *ServerList) Updating() bool {
+	return list.currentlyUpdating.Len() > 0
+}
+
+func (list *ServerList) Servers() *resource.ServerList {
+	return &list.servers
+}
@@ -0,0 +1,44 @@
+package nlwindows
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+	"github.com/I-Am-Dench/nimbus-launcher/app/nlwidgets"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+)
+
+// Returns a single line describing the metadata's release date, download size, and whether the
+// version is mandatory, or an empty string if none of them are set.
+func metadataDetails(metadata patch.Metadata) string {
+	details := []string{}
+
+	if date, ok := metadata.Released(); ok {
+		details = append(details, fmt.Sprintf("Released: %s", date.Format("January 2, 2006")))
+	}
+
+	if metadata.DownloadSize > 0 {
+		details = append(details, fmt.Sprintf("Download Size: %s", nlwidgets.FormatBytes(metadata.DownloadSize)))
+	}
+
+	if metadata.Mandatory {
+		details = append(details, "Mandatory")
+	}
+
+	return strings.Join(details, " | ")
+}
+
+// Returns the metadata's changelog rendered from Markdown, or a placeholder if there is no changelog.
+func changelogText(metadata patch.Metadata) *widget.RichText {
+	changelog := metadata.Changelog
+	if len(strings.TrimSpace(changelog)) == 0 {
+		changelog = "*No release notes.*"
+	}
+
+	text := widget.NewRichTextFromMarkdown(changelog)
+	text.Wrapping = fyne.TextWrapWord
+
+	return text
+}
@@ -0,0 +1,148 @@
+package nlwindows
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
+)
+
+// Returns a window which shows that the server's patch history is loading. The history is shown once
+// it is loaded with LoadPatchHistoryContainer.
+func NewPatchHistoryWindow(app fyne.App, serv *server.Server) fyne.Window {
+	window := app.NewWindow(fmt.Sprintf("Patch History - %s", serv.Name))
+	window.SetFixedSize(true)
+	window.Resize(fyne.NewSize(800, 450))
+	window.SetIcon(theme.HistoryIcon())
+
+	window.SetContent(
+		container.NewPadded(
+			container.NewVBox(
+				widget.NewLabel("Fetching patch history..."),
+				widget.NewProgressBarInfinite(),
+			),
+		),
+	)
+
+	return window
+}
+
+func LoadPatchHistoryContainer(window fyne.Window, serv *server.Server, summary patch.Summary, rejections *patch.RejectionList, onInstall func(version string)) {
+	heading := canvas.NewText("Available patch versions:", theme.ForegroundColor())
+	heading.TextSize = 16
+
+	versions := summary.Listed()
+
+	label := func(version string) string {
+		tags := []string{}
+		if version == serv.CurrentPatch {
+			tags = append(tags, "installed")
+		}
+
+		if version == summary.CurrentVersion {
+			tags = append(tags, "latest")
+		}
+
+		if rejections.IsRejected(serv, version) {
+			tags = append(tags, "rejected")
+		}
+
+		if patch.ValidateVersionName(version) != nil {
+			tags = append(tags, "invalid")
+		}
+
+		if summary.Versions[version].Mandatory {
+			tags = append(tags, "mandatory")
+		}
+
+		name := version
+		if title := summary.Versions[version].Title; len(title) > 0 {
+			name = fmt.Sprintf("%s - %s", version, title)
+		}
+
+		if len(tags) == 0 {
+			return name
+		}
+
+		return fmt.Sprintf("%s (%s)", name, strings.Join(tags, ", "))
+	}
+
+	selected := ""
+
+	install := widget.NewButtonWithIcon("Install", theme.DownloadIcon(), func() {
+		window.Close()
+		onInstall(selected)
+	})
+	install.Importance = widget.HighImportance
+	install.Disable()
+
+	list := widget.NewList(
+		func() int {
+			return len(versions)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*widget.Label).SetText(label(versions[id]))
+		},
+	)
+	detailsTitle := widget.NewLabelWithStyle("Select a version to view its release notes.", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	detailsTitle.Wrapping = fyne.TextWrapWord
+	detailsInfo := widget.NewLabel("")
+	detailsInfo.Wrapping = fyne.TextWrapWord
+	changelog := container.NewStack()
+
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = versions[id]
+
+		metadata := summary.Versions[selected]
+		if len(metadata.Title) > 0 {
+			detailsTitle.SetText(fmt.Sprintf("%s - %s", selected, metadata.Title))
+		} else {
+			detailsTitle.SetText(selected)
+		}
+		detailsInfo.SetText(metadataDetails(metadata))
+		changelog.Objects = []fyne.CanvasObject{changelogText(metadata)}
+		changelog.Refresh()
+
+		if selected == serv.CurrentPatch || rejections.IsRejected(serv, selected) || patch.ValidateVersionName(selected) != nil {
+			install.Disable()
+		} else {
+			install.Enable()
+		}
+	}
+
+	cancel := widget.NewButton("Cancel", window.Close)
+
+	footer := container.NewBorder(
+		nil, nil,
+		widget.NewLabel(fmt.Sprintf("Installed: %s", serv.CurrentPatch)),
+		container.NewHBox(cancel, install),
+	)
+
+	details := container.NewBorder(
+		container.NewVBox(detailsTitle, detailsInfo), nil,
+		nil, nil,
+		container.NewVScroll(changelog),
+	)
+
+	split := container.NewHSplit(list, details)
+	split.SetOffset(0.45)
+
+	window.SetContent(
+		container.NewPadded(
+			container.NewBorder(
+				heading, footer,
+				nil, nil,
+				split,
+			),
+		),
+	)
+}
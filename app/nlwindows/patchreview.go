@@ -1,8 +1,8 @@
 package nlwindows
 
 import (
-	"encoding/json"
 	"fmt"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -21,21 +21,137 @@ const (
 	PatchReject
 )
 
-func NewPatchReviewWindow(app fyne.App, patch patch.Patch, onConfirmCancel func(PatchAcceptState)) fyne.Window {
+func NewPatchReviewWindow(app fyne.App, patch patch.Patch, plan *patch.Plan, metadata patch.Metadata, onConfirmCancel func(PatchAcceptState)) fyne.Window {
 	window := app.NewWindow("Review Patch")
 	window.SetFixedSize(true)
 	window.Resize(fyne.NewSize(800, 600))
 	window.SetIcon(theme.QuestionIcon())
 
-	LoadPatchReviewContainer(window, patch, onConfirmCancel)
+	LoadPatchReviewContainer(window, patch, plan, metadata, onConfirmCancel)
 
 	return window
 }
 
-func LoadPatchReviewContainer(window fyne.Window, patch patch.Patch, onConfirmCancel func(PatchAcceptState)) {
-	heading := canvas.NewText(fmt.Sprintf("Received patch.json (%s):", patch.Version()), theme.ForegroundColor())
+func planDownloads(plan *patch.Plan) (string, string) {
+	size, known := plan.DownloadSize()
+	total := nlwidgets.FormatBytes(size)
+	if !known {
+		total = fmt.Sprintf("at least %s", total)
+	}
+
+	lines := []string{}
+	for _, download := range plan.Downloads {
+		size := "unknown size"
+		if download.Size >= 0 {
+			size = nlwidgets.FormatBytes(download.Size)
+		}
+
+		lines = append(lines, fmt.Sprintf("[%s] %s -> %s (%s)", download.Version, download.Path, download.Name, size))
+	}
+
+	return fmt.Sprintf("Downloads: %d file(s), %s", len(plan.Downloads), total), strings.Join(lines, "\n")
+}
+
+func planExtractions(plan *patch.Plan) (string, string) {
+	lines := []string{}
+	for _, extraction := range plan.Extractions {
+		lines = append(lines, fmt.Sprintf("[%s] %s -> %s", extraction.Version, extraction.Archive, extraction.Directory))
+	}
+
+	return fmt.Sprintf("Extractions: %d archive(s)", len(plan.Extractions)), strings.Join(lines, "\n")
+}
+
+func planTransfers(plan *patch.Plan) (string, string) {
+	lines := []string{}
+	for _, transfer := range plan.Transfers {
+		note := ""
+		switch {
+		case transfer.Directive == "add" && transfer.Exists:
+			note = " (already exists)"
+		case transfer.Directive == "add":
+			note = " (new)"
+		case !transfer.Exists:
+			note = " (does not exist)"
+		}
+
+		if len(transfer.Source) > 0 {
+			lines = append(lines, fmt.Sprintf("[%s] %s %s <- %s%s", transfer.Version, transfer.Directive, transfer.Path, transfer.Source, note))
+		} else {
+			lines = append(lines, fmt.Sprintf("[%s] %s %s%s", transfer.Version, transfer.Directive, transfer.Path, note))
+		}
+	}
+
+	return fmt.Sprintf("Client Changes: %d file(s)", len(plan.Transfers)), strings.Join(lines, "\n")
+}
+
+func planConflicts(plan *patch.Plan) (string, string) {
+	lines := []string{}
+	undeclared := 0
+
+	for _, conflict := range plan.Conflicts {
+		note := ""
+		switch {
+		case conflict.Fatal():
+			note = " (cannot be applied)"
+		case !conflict.Declared:
+			note = " (undeclared)"
+		}
+
+		if !conflict.Declared {
+			undeclared++
+		}
+
+		lines = append(lines, fmt.Sprintf("%s: [%s] %s is overridden by [%s] %s%s", conflict.Path, conflict.Version, conflict.Directive, conflict.OverriddenBy, conflict.OverridingDirective, note))
+	}
+
+	return fmt.Sprintf("Conflicts: %d file(s), %d undeclared", len(plan.Conflicts), undeclared), strings.Join(lines, "\n")
+}
+
+func planUpdates(plan *patch.Plan) (string, string) {
+	lines := []string{}
+
+	if len(plan.BootFile) > 0 {
+		if plan.BootChanges == nil {
+			lines = append(lines, fmt.Sprintf("boot.cfg is replaced by %s", plan.BootFile))
+		} else if len(plan.BootChanges) == 0 {
+			lines = append(lines, fmt.Sprintf("boot.cfg is replaced by %s (unchanged)", plan.BootFile))
+		}
+
+		for _, change := range plan.BootChanges {
+			lines = append(lines, fmt.Sprintf("%s: \"%s\" -> \"%s\"", change.Key, change.Old, change.New))
+		}
+	}
+
+	if len(plan.Protocol) > 0 {
+		lines = append(lines, fmt.Sprintf("Patch protocol -> %s", plan.Protocol))
+	}
+
+	return fmt.Sprintf("Server Updates: %d change(s)", len(lines)), strings.Join(lines, "\n")
+}
+
+// Returns an accordion item whose details are the lines, or a placeholder if there are none.
+func planItem(title, lines string) *widget.AccordionItem {
+	if len(lines) == 0 {
+		lines = "(None)"
+	}
+
+	details := widget.NewLabel(lines)
+	details.TextStyle.Monospace = true
+
+	return widget.NewAccordionItem(title, details)
+}
+
+func LoadPatchReviewContainer(window fyne.Window, patch patch.Patch, plan *patch.Plan, metadata patch.Metadata, onConfirmCancel func(PatchAcceptState)) {
+	title := fmt.Sprintf("Received patch (%s):", patch.Version())
+	if len(metadata.Title) > 0 {
+		title = fmt.Sprintf("Received patch (%s): %s", patch.Version(), metadata.Title)
+	}
+
+	heading := canvas.NewText(title, theme.ForegroundColor())
 	heading.TextSize = 16
 
+	summary := widget.NewLabel(patch.Summary())
+
 	reject := widget.NewButton(
 		"Reject", func() {
 			window.Close()
@@ -44,6 +160,11 @@ func LoadPatchReviewContainer(window fyne.Window, patch patch.Patch, onConfirmCa
 	)
 	reject.Importance = widget.DangerImportance
 
+	// Mandatory versions must be installed before playing, so they cannot be rejected
+	if metadata.Mandatory {
+		reject.Disable()
+	}
+
 	confirm := widget.NewButton(
 		"Continue", func() {
 			window.Close()
@@ -52,6 +173,12 @@ func LoadPatchReviewContainer(window fyne.Window, patch patch.Patch, onConfirmCa
 	)
 	confirm.Importance = widget.HighImportance
 
+	for _, conflict := range plan.Conflicts {
+		if conflict.Fatal() {
+			confirm.Disable()
+		}
+	}
+
 	cancel := widget.NewButton(
 		"Cancel", func() {
 			window.Close()
@@ -70,17 +197,35 @@ func LoadPatchReviewContainer(window fyne.Window, patch patch.Patch, onConfirmCa
 		reject, container.NewHBox(cancel, confirm),
 	)
 
-	data, _ := json.MarshalIndent(patch, "", "    ")
-	patchContent := nlwidgets.NewCodeBox()
-	patchContent.SetText(string(data))
+	versions := widget.NewLabel(fmt.Sprintf("Applies: %s", strings.Join(plan.Versions, " -> ")))
+
+	header := container.NewVBox(heading, summary, versions)
+	if details := metadataDetails(metadata); len(details) > 0 {
+		header.Add(widget.NewLabel(details))
+	}
+	if plan.Conditionals > 0 {
+		header.Add(widget.NewLabel(fmt.Sprintf("Conditional Blocks: %d of %d apply (%s)", plan.AppliedConditionals, plan.Conditionals, plan.Conditions)))
+	}
+
+	downloads := planItem(planDownloads(plan))
+	extractions := planItem(planExtractions(plan))
+	transfers := planItem(planTransfers(plan))
+	conflicts := planItem(planConflicts(plan))
+	updates := planItem(planUpdates(plan))
+
+	notes := widget.NewAccordionItem("Release Notes", changelogText(metadata))
+
+	planContent := widget.NewAccordion(notes, downloads, extractions, transfers, conflicts, updates)
+	planContent.MultiOpen = true
+	planContent.OpenAll()
 
 	window.SetContent(
 		container.NewPadded(
 			container.NewBorder(
-				heading, footer,
+				header, footer,
 				nil, nil,
 				container.NewVScroll(
-					patchContent,
+					planContent,
 				),
 			),
 		),
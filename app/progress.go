@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/I-Am-Dench/nimbus-launcher/app/nlwidgets"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+)
+
+// The minimum amount of time between progress bar refreshes while bytes are being written.
+const progressRefreshInterval = 100 * time.Millisecond
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// Drives a BinaryProgressBar from the events emitted while a patch is updated.
+//
+// If the total size of the downloads is known, the progress bar displays the number of bytes downloaded
+// and the estimated time remaining. Otherwise, the progress bar displays the number of files downloaded.
+type patchProgress struct {
+	bar *nlwidgets.BinaryProgressBar
+
+	mux         sync.Mutex
+	start       time.Time
+	lastRefresh time.Time
+
+	files    int
+	finished int
+
+	size    int64
+	written int64
+	resumed int64
+}
+
+func newPatchProgress(bar *nlwidgets.BinaryProgressBar) *patchProgress {
+	return &patchProgress{bar: bar}
+}
+
+func (progress *patchProgress) Notify(event patch.Event) {
+	progress.mux.Lock()
+	defer progress.mux.Unlock()
+
+	switch event.Kind {
+	case patch.DownloadStarted:
+		progress.start = time.Now()
+		progress.files = event.Count
+		progress.finished = 0
+		progress.size = event.Size
+		progress.written = 0
+		progress.resumed = 0
+	case patch.FileStarted:
+		progress.written += event.Bytes
+		progress.resumed += event.Bytes
+	case patch.BytesWritten:
+		progress.written += event.Bytes
+		if time.Since(progress.lastRefresh) < progressRefreshInterval {
+			return
+		}
+	case patch.FileFinished:
+		progress.finished++
+	case patch.DirectiveApplied:
+		progress.bar.ShowFormat(fmt.Sprintf("Applied %s: %s", event.Directive, event.Path))
+		return
+	default:
+		return
+	}
+
+	progress.refresh()
+}
+
+func (progress *patchProgress) eta() string {
+	downloaded := progress.written - progress.resumed
+	elapsed := time.Since(progress.start)
+	if downloaded <= 0 || elapsed <= 0 {
+		return "--:--"
+	}
+
+	rate := float64(downloaded) / elapsed.Seconds()
+	remaining := float64(progress.size-progress.written) / rate
+	return formatDuration(time.Duration(remaining * float64(time.Second)))
+}
+
+func (progress *patchProgress) refresh() {
+	progress.lastRefresh = time.Now()
+
+	if progress.size > 0 {
+		progress.bar.SetMax(float64(progress.size))
+		progress.bar.ShowValue(
+			float64(min(progress.written, progress.size)),
+			fmt.Sprintf("Downloading: %s / %s (ETA %s)", nlwidgets.FormatBytes(progress.written), nlwidgets.FormatBytes(progress.size), progress.eta()),
+		)
+		return
+	}
+
+	if progress.files > 0 {
+		progress.bar.SetMax(float64(progress.files))
+		progress.bar.ShowValue(
+			float64(progress.finished),
+			fmt.Sprintf("Downloading: $VALUE/$MAX file(s) (%s)", nlwidgets.FormatBytes(progress.written)),
+		)
+	}
+}
@@ -218,9 +218,15 @@ func (page *ServersPage) editServerPage(form *forms.ServerForm, window fyne.Wind
 
 			id := server.ID
 			version := server.CurrentPatch
+			pinned := server.PatchPinned
+			advertisedMirrors := server.AdvertisedMirrors
+			runner := server.PatchRunner
 			*server = *form.Get()
 			server.ID = id
 			server.CurrentPatch = version
+			server.PatchPinned = pinned
+			server.AdvertisedMirrors = advertisedMirrors
+			server.PatchRunner = runner
 
 			err := server.SaveConfig()
 			if err != nil {
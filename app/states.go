@@ -25,6 +25,7 @@ func (app *App) SetNormalState() {
 	app.playButton.Refresh()
 
 	app.refreshUpdatesButton.Enable()
+	app.patchHistoryButton.Enable()
 
 	app.serverList.Enable()
 }
@@ -33,10 +34,16 @@ func (app *App) SetUpdatingState() {
 	app.progressBar.ShowIndefinite()
 
 	app.serverList.Disable()
-	app.playButton.Disable()
-	app.playButton.SetText("Updating")
+
+	app.playButton.Enable()
+	app.playButton.SetText("Cancel")
+	app.playButton.SetIcon(theme.CancelIcon())
+	app.playButton.Importance = widget.DangerImportance
+	app.playButton.OnTapped = app.PressCancel
+	app.playButton.Refresh()
 
 	app.refreshUpdatesButton.Disable()
+	app.patchHistoryButton.Disable()
 }
 
 func (app *App) SetUpdateState() {
@@ -50,6 +57,7 @@ func (app *App) SetUpdateState() {
 	app.playButton.Refresh()
 
 	app.refreshUpdatesButton.Enable()
+	app.patchHistoryButton.Enable()
 }
 
 func (app *App) SetCheckingUpdatesState() {
@@ -62,4 +70,5 @@ func (app *App) SetCheckingUpdatesState() {
 	app.playButton.Refresh()
 
 	app.refreshUpdatesButton.Disable()
+	app.patchHistoryButton.Disable()
 }
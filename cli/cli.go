@@ -0,0 +1,95 @@
+// Package cli implements the launcher's command line tools for patch server owners.
+//
+// The tools are run as subcommands of the launcher executable:
+//
+//	nimbus-launcher keygen -out server
+//	nimbus-launcher sign -key server.key patches/summary.json patches/v1.0.0/patch.json
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type Command struct {
+	Name  string
+	Usage string
+
+	// A single line description shown by the help command.
+	Description string
+
+	Run func(flags *flag.FlagSet, args []string) error
+}
+
+var commands = make(map[string]Command)
+
+// Makes the command available to Run. Commands are expected to be registered within an init function.
+func Register(command Command) {
+	if _, ok := commands[command.Name]; ok {
+		panic(fmt.Sprintf("cli: command \"%s\" is already registered", command.Name))
+	}
+
+	commands[command.Name] = command
+}
+
+// Returns true if name is a registered command.
+func IsCommand(name string) bool {
+	if name == "help" {
+		return true
+	}
+
+	_, ok := commands[name]
+	return ok
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: nimbus-launcher <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-12s %s\n", name, commands[name].Description)
+	}
+}
+
+// Runs the command named by args[0] with the remaining arguments, and returns the process exit code.
+func Run(args []string) int {
+	if len(args) == 0 || args[0] == "help" {
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command \"%s\"\n", args[0])
+		printUsage(os.Stderr)
+		return 2
+	}
+
+	flags := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: nimbus-launcher %s %s\n", command.Name, command.Usage)
+		flags.PrintDefaults()
+	}
+
+	err := command.Run(flags, args[1:])
+	if err == flag.ErrHelp {
+		return 0
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command.Name, err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch/delta"
+)
+
+func init() {
+	Register(Command{
+		Name:        "make-delta",
+		Usage:       "-source file -target file [-out file] [-path resource]",
+		Description: "Creates a binary delta for the delta directive",
+		Run:         makeDelta,
+	})
+}
+
+func makeDelta(flags *flag.FlagSet, args []string) error {
+	sourceName := flags.String("source", "", "the pristine client resource")
+	targetName := flags.String("target", "", "the patched client resource")
+	out := flags.String("out", "", "the name of the created delta (default \"{target}.delta\")")
+	path := flags.String("path", "", "the client resource path used within the printed directive")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*sourceName) == 0 || len(*targetName) == 0 {
+		flags.Usage()
+		return errors.New("missing source or target")
+	}
+
+	if len(*out) == 0 {
+		*out = *targetName + ".delta"
+	}
+
+	source, err := os.ReadFile(*sourceName)
+	if err != nil {
+		return fmt.Errorf("could not read source: %w", err)
+	}
+
+	target, err := os.ReadFile(*targetName)
+	if err != nil {
+		return fmt.Errorf("could not read target: %w", err)
+	}
+
+	d, err := delta.Diff(source, target)
+	if err != nil {
+		return fmt.Errorf("could not create delta: %w", err)
+	}
+
+	err = os.WriteFile(*out, d, 0644)
+	if err != nil {
+		return fmt.Errorf("could not save delta: %w", err)
+	}
+
+	fmt.Printf("Saved \"%s\" (%d byte(s); target is %d byte(s))\n\n", *out, len(d), len(target))
+
+	sum := sha256.Sum256(target)
+	entry := patch.DeltaEntry{
+		Path:   *path,
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+
+	if len(entry.Path) == 0 {
+		entry.Path = filepath.ToSlash(*sourceName)
+	}
+
+	directive, _ := json.MarshalIndent(map[string]any{
+		"delta": map[string]patch.DeltaEntry{
+			filepath.Base(*out): entry,
+		},
+	}, "", "    ")
+	fmt.Println(string(directive))
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+)
+
+func init() {
+	Register(Command{
+		Name:        "lint-patch",
+		Usage:       "[-runner runner] [-quick] directory",
+		Description: "Checks a Server Patch Directory, or a single version directory, for problems",
+		Run:         lintPatch,
+	})
+}
+
+// Returns the problems within the patch.json of the version directory, where root is the Server Patch Directory.
+func lintVersion(root, version, runner string, options patch.LintOptions) ([]patch.Problem, error) {
+	data, err := os.ReadFile(filepath.Join(root, version, "patch.json"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read patch.json: %w", err)
+	}
+
+	p, err := patch.New(runner, version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, p)
+	if err != nil {
+		return []patch.Problem{{File: version + "/patch.json", Message: err.Error()}}, nil
+	}
+
+	return patch.Lint(p, options), nil
+}
+
+func lintPatch(flags *flag.FlagSet, args []string) error {
+	runner := flags.String("runner", "", "the runner of the patches, if there is no summary.json (default: "+patch.DefaultRunner+")")
+	quick := flags.Bool("quick", false, "do not check the size and hash of each download")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a single directory")
+	}
+
+	dir := filepath.Clean(flags.Arg(0))
+	root, versions := dir, []string{}
+	problems := []patch.Problem{}
+
+	if _, err := os.Stat(filepath.Join(dir, "patch.json")); err == nil {
+		root, versions = filepath.Dir(dir), []string{filepath.Base(dir)}
+	} else {
+		data, err := os.ReadFile(filepath.Join(dir, "summary.json"))
+		if err != nil {
+			return fmt.Errorf("\"%s\" contains neither a patch.json nor a summary.json", dir)
+		}
+
+		summary := patch.Summary{}
+		if err := json.Unmarshal(data, &summary); err != nil {
+			return fmt.Errorf("could not unmarshal summary.json: %w", err)
+		}
+
+		if len(summary.Runner) > 0 {
+			*runner = summary.Runner
+		}
+
+		problems = append(problems, summary.Lint()...)
+		versions = summary.AvailableVersions
+	}
+
+	options := patch.LintOptions{
+		Read: func(path string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(path, "/"))))
+		},
+		VerifyDownloads: !*quick,
+	}
+
+	for _, version := range versions {
+		if err := patch.ValidateVersionName(version); err != nil {
+			// Already reported by the summary
+			continue
+		}
+
+		versionProblems, err := lintVersion(root, version, *runner, options)
+		if err != nil {
+			problems = append(problems, patch.Problem{File: version + "/patch.json", Message: err.Error()})
+			continue
+		}
+
+		problems = append(problems, versionProblems...)
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problem(s)", len(problems))
+	}
+
+	fmt.Printf("Checked %d patch(es); No problems found\n", len(versions))
+	return nil
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch/authoring"
+)
+
+func init() {
+	Register(Command{
+		Name:        "make-patch",
+		Usage:       "-pristine directory -modified directory -version version [-patches directory] [-depend] [-remove]",
+		Description: "Creates a patch from the differences between two client directories",
+		Run:         makePatch,
+	})
+}
+
+func makePatch(flags *flag.FlagSet, args []string) error {
+	pristine := flags.String("pristine", "", "the unmodified client directory, or with -depend, the client produced by the current version")
+	modified := flags.String("modified", "", "the client directory containing the patch's changes")
+	version := flags.String("version", "", "the version of the created patch")
+	patchDir := flags.String("patches", "patches", "the Server Patch Directory")
+	depend := flags.Bool("depend", false, "depend on the current version of the summary.json")
+	remove := flags.Bool("remove", false, "remove resources which do not exist within the modified client")
+	keepCurrent := flags.Bool("keep-current", false, "do not set the patch as the current version of the summary.json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*pristine) == 0 || len(*modified) == 0 || len(*version) == 0 {
+		flags.Usage()
+		return errors.New("missing pristine, modified, or version")
+	}
+
+	result, err := authoring.MakePatch(authoring.Options{
+		Pristine:         *pristine,
+		Modified:         *modified,
+		PatchDir:         *patchDir,
+		Version:          *version,
+		Remove:           *remove,
+		DependOnPrevious: *depend,
+		KeepCurrent:      *keepCurrent,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created \"%s\" (%d byte(s) of resources)\n", filepath.Join(*patchDir, *version), result.Size)
+	fmt.Printf("  %d replaced, %d added, %d removed\n", len(result.Replaced), len(result.Added), len(result.Removed))
+
+	if len(result.Patch.Dependencies) > 0 {
+		fmt.Printf("  depends on %s\n", result.Patch.Dependencies[0])
+	}
+
+	signature := filepath.Join(*patchDir, "summary.json"+patch.SignatureSuffix)
+	if _, err := os.Stat(signature); err == nil {
+		fmt.Printf("\n\"%s\" is out of date; Sign summary.json and the new patch.json again before uploading them.\n", signature)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch/tppserver"
+)
+
+func init() {
+	Register(Command{
+		Name:        "serve",
+		Usage:       "[-dir directory] [-addr address] [-prefix path] [-token tokens] [-cert file -key file]",
+		Description: "Serves a Server Patch Directory as a TPP patch server",
+		Run:         serve,
+	})
+}
+
+// Splits a comma separated list, leaving out empty values.
+func splitList(list string) []string {
+	values := []string{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); len(value) > 0 {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func serve(flags *flag.FlagSet, args []string) error {
+	dir := flags.String("dir", "patches", "the Server Patch Directory")
+	addr := flags.String("addr", ":3000", "the address to listen on")
+	prefix := flags.String("prefix", "/patches", "the URL path the directory is served from, i.e. PATCHSERVERDIR")
+	tokens := flags.String("token", "", "a comma separated list of accepted TPP-Token values; if empty, no token is required")
+	current := flags.String("current", "", "the current version of the generated summary.json (default: the greatest version)")
+	runner := flags.String("runner", "", "the runner of the generated summary.json")
+	mirrors := flags.String("mirrors", "", "a comma separated list of mirrors advertised by the generated summary.json")
+	certFile := flags.String("cert", "", "the TLS certificate; if set, the server uses https")
+	keyFile := flags.String("key", "", "the TLS private key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if (len(*certFile) == 0) != (len(*keyFile) == 0) {
+		flags.Usage()
+		return errors.New("both -cert and -key must be set to use https")
+	}
+
+	stat, err := os.Stat(*dir)
+	if err != nil {
+		return fmt.Errorf("could not open patch directory: %w", err)
+	}
+
+	if !stat.IsDir() {
+		return fmt.Errorf("\"%s\" is not a directory", *dir)
+	}
+
+	server := tppserver.New(tppserver.Config{
+		FS:             os.DirFS(*dir),
+		Prefix:         *prefix,
+		Tokens:         splitList(*tokens),
+		CurrentVersion: *current,
+		Runner:         *runner,
+		Mirrors:        splitList(*mirrors),
+	})
+
+	summary, err := server.Summary()
+	if err != nil {
+		return err
+	}
+	log.Printf("Serving \"%s\" at %s; Current version: \"%s\" (%d available)", *dir, *prefix, summary.CurrentVersion, len(summary.AvailableVersions))
+
+	handler := tppserver.Logged(server)
+	if len(*certFile) > 0 {
+		return http.ListenAndServeTLS(*addr, *certFile, *keyFile, handler)
+	}
+
+	return http.ListenAndServe(*addr, handler)
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+)
+
+func init() {
+	Register(Command{
+		Name:        "keygen",
+		Usage:       "[-out name]",
+		Description: "Generates an Ed25519 key pair for signing patches",
+		Run:         keygen,
+	})
+
+	Register(Command{
+		Name:        "sign",
+		Usage:       "-key file files...",
+		Description: "Writes a detached signature for each of the files",
+		Run:         sign,
+	})
+
+	Register(Command{
+		Name:        "verify",
+		Usage:       "-pub key files...",
+		Description: "Checks the detached signature of each of the files",
+		Run:         verify,
+	})
+}
+
+func keygen(flags *flag.FlagSet, args []string) error {
+	out := flags.String("out", "patch", "the name of the generated key files, without an extension")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	publicKey, privateKey, err := patch.GenerateKey()
+	if err != nil {
+		return fmt.Errorf("could not generate key: %w", err)
+	}
+
+	err = os.WriteFile(*out+".key", []byte(privateKey), 0600)
+	if err != nil {
+		return fmt.Errorf("could not save private key: %w", err)
+	}
+
+	err = os.WriteFile(*out+".pub", []byte(publicKey), 0644)
+	if err != nil {
+		return fmt.Errorf("could not save public key: %w", err)
+	}
+
+	fmt.Printf("Private key saved to \"%s.key\"; Keep this file secret.\n", *out)
+	fmt.Printf("Public key saved to \"%s.pub\":\n\n%s\n", *out, publicKey)
+	return nil
+}
+
+func sign(flags *flag.FlagSet, args []string) error {
+	keyFile := flags.String("key", "", "the private key file generated by keygen")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*keyFile) == 0 || flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("missing private key or files")
+	}
+
+	data, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return fmt.Errorf("could not read private key: %w", err)
+	}
+
+	privateKey, err := patch.ParsePrivateKey(string(data))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range flags.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("could not read \"%s\": %w", name, err)
+		}
+
+		signatureName := name + patch.SignatureSuffix
+		err = os.WriteFile(signatureName, patch.Sign(privateKey, data), 0644)
+		if err != nil {
+			return fmt.Errorf("could not save signature \"%s\": %w", signatureName, err)
+		}
+
+		fmt.Printf("Signed \"%s\" -> \"%s\"\n", name, signatureName)
+	}
+
+	return nil
+}
+
+func verify(flags *flag.FlagSet, args []string) error {
+	publicKey := flags.String("pub", "", "the base64 encoded public key, or a file containing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*publicKey) == 0 || flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("missing public key or files")
+	}
+
+	key := *publicKey
+	if data, err := os.ReadFile(key); err == nil {
+		key = string(data)
+	}
+
+	if _, err := patch.ParsePublicKey(key); err != nil {
+		return err
+	}
+
+	failed := false
+	for _, name := range flags.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("could not read \"%s\": %w", name, err)
+		}
+
+		signature, err := os.ReadFile(name + patch.SignatureSuffix)
+		if err == nil {
+			err = patch.VerifySignature(key, data, signature)
+		}
+
+		if err != nil {
+			fmt.Printf("FAIL \"%s\": %v\n", name, err)
+			failed = true
+			continue
+		}
+
+		fmt.Printf("OK   \"%s\"\n", name)
+	}
+
+	if failed {
+		return errors.New("one or more signatures could not be verified")
+	}
+
+	return nil
+}
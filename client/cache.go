@@ -26,9 +26,18 @@ type Cache[T any] interface {
 	Has(key string) bool
 }
 
+// Records the paths of the client resources changed by a transfer which has not yet completed.
+type Journal interface {
+	Cache[string]
+
+	// Removes every path from the journal.
+	Clear() error
+}
+
 type Resources interface {
 	Replacements() Cache[Resource]
 	Additions() Cache[string]
+	Journal() Journal
 
 	Close() error
 }
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Restores every resource recorded in the journal to its cached, unpatched state, and then clears
+// the journal. Replaced resources are rewritten from the replacements cache, and added resources are
+// deleted. A recorded resource which has not been cached was never changed, so it is left as is.
+//
+// Rollback should be called after a transfer fails, or when the launcher starts, in case a transfer
+// was interrupted.
+func Rollback(clientDirectory string, resources Resources) error {
+	paths, err := resources.Journal().List()
+	if err != nil {
+		return fmt.Errorf("client: cannot read transfer journal: %w", err)
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	log.Printf("Rolling back %d resource(s)", len(paths))
+
+	errs := []error{}
+	for _, path := range paths {
+		if resources.Replacements().Has(path) {
+			resource, err := resources.Replacements().Get(path)
+			if err == nil {
+				os.MkdirAll(filepath.Dir(filepath.Join(clientDirectory, path)), 0755)
+				err = WriteResource(clientDirectory, resource)
+			}
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("client: cannot restore \"%s\": %w", path, err))
+			}
+			continue
+		}
+
+		if resources.Additions().Has(path) {
+			err := RemoveResource(clientDirectory, path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		// The journal is kept so that the rollback can be retried
+		return errors.Join(errs...)
+	}
+
+	return resources.Journal().Clear()
+}
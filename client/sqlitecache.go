@@ -18,6 +18,12 @@ const (
 	INSERT_ADDED_RESOURCE     = "INSERT INTO added_resources VALUES (?)"
 	QUERY_ALL_ADDED_RESOURCES = "SELECT * FROM added_resources"
 	QUERY_ADDED_RESOURCE      = "SELECT * FROM added_resources WHERE path = ?"
+
+	CREATE_TRANSFER_JOURNAL    = "CREATE TABLE IF NOT EXISTS transfer_journal (path TEXT UNIQUE PRIMARY KEY)"
+	INSERT_JOURNAL_ENTRY       = "INSERT INTO transfer_journal VALUES (?)"
+	QUERY_ALL_JOURNAL_ENTRIES  = "SELECT * FROM transfer_journal"
+	QUERY_JOURNAL_ENTRY        = "SELECT * FROM transfer_journal WHERE path = ?"
+	DELETE_ALL_JOURNAL_ENTRIES = "DELETE FROM transfer_journal"
 )
 
 type sqliteBase struct {
@@ -126,9 +132,59 @@ func (cache *sqliteAdditions) Has(path string) bool {
 	return err == nil
 }
 
+type sqliteJournal struct {
+	sqliteBase
+}
+
+func (journal *sqliteJournal) Add(path string) error {
+	_, err := journal.Execute(INSERT_JOURNAL_ENTRY, path)
+	return err
+}
+
+func (journal *sqliteJournal) Get(path string) (string, error) {
+	var queriedPath string
+	row := journal.QueryRow(QUERY_JOURNAL_ENTRY, path)
+
+	err := row.Scan(&queriedPath)
+	if err != nil {
+		return "", fmt.Errorf("sqlite transfer journal: could not query path: %w", err)
+	}
+
+	return queriedPath, nil
+}
+
+func (journal *sqliteJournal) List() ([]string, error) {
+	rows, err := journal.Query(QUERY_ALL_JOURNAL_ENTRIES)
+	if err != nil {
+		return []string{}, err
+	}
+
+	paths := []string{}
+	for rows.Next() {
+		var path string
+		err := rows.Scan(&path)
+		if err == nil {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+func (journal *sqliteJournal) Has(path string) bool {
+	_, err := journal.Get(path)
+	return err == nil
+}
+
+func (journal *sqliteJournal) Clear() error {
+	_, err := journal.Execute(DELETE_ALL_JOURNAL_ENTRIES)
+	return err
+}
+
 type sqliteResources struct {
 	replacements sqliteReplacements
 	additions    sqliteAdditions
+	journal      sqliteJournal
 
 	db     *sql.DB
 	cancel context.CancelFunc
@@ -146,10 +202,12 @@ func NewSqliteResources(path string) (Resources, error) {
 
 	replacements := sqliteReplacements{sqliteBase{db, ctx}}
 	additions := sqliteAdditions{sqliteBase{db, ctx}}
+	journal := sqliteJournal{sqliteBase{db, ctx}}
 
 	resources := &sqliteResources{
 		replacements: replacements,
 		additions:    additions,
+		journal:      journal,
 
 		db:     db,
 		cancel: cancel,
@@ -167,6 +225,12 @@ func NewSqliteResources(path string) (Resources, error) {
 		return nil, fmt.Errorf("could not initialize sqlite additions cache: %w", err)
 	}
 
+	_, err = resources.db.ExecContext(ctx, CREATE_TRANSFER_JOURNAL)
+	if err != nil {
+		resources.Close()
+		return nil, fmt.Errorf("could not initialize sqlite transfer journal: %w", err)
+	}
+
 	return resources, nil
 }
 
@@ -178,6 +242,10 @@ func (resources *sqliteResources) Additions() Cache[string] {
 	return &resources.additions
 }
 
+func (resources *sqliteResources) Journal() Journal {
+	return &resources.journal
+}
+
 func (resources *sqliteResources) Close() error {
 	resources.cancel()
 	return resources.db.Close()
@@ -6,11 +6,16 @@ import (
 	"os"
 
 	"github.com/I-Am-Dench/nimbus-launcher/app"
+	"github.com/I-Am-Dench/nimbus-launcher/cli"
 	"github.com/I-Am-Dench/nimbus-launcher/resource"
 	"github.com/I-Am-Dench/nimbus-launcher/version"
 )
 
 func main() {
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	log.Printf("Starting Nimbus Launcher (%v)", version.Get())
 
 	err := resource.InitializeSettings()
@@ -0,0 +1,443 @@
+// Package authoring creates TPP patches by comparing a pristine client directory with a modified
+// copy of the client.
+//
+// The patches can be created with the launcher's make-patch subcommand:
+//
+//	nimbus-launcher make-patch -pristine client -modified modded -patches patches -version v1.1.0
+package authoring
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+)
+
+// The directory within the version directory which contains the patch's resources, so that client
+// resources never collide with the patch.json.
+const resourcesDir = "resources"
+
+type Options struct {
+	// The unmodified client directory. If DependOnPrevious is true, this must instead be the client
+	// produced by the previous version, since the patch is applied after it.
+	Pristine string
+
+	// The client directory containing the changes made by the patch.
+	Modified string
+
+	// The Server Patch Directory which the version directory is created within.
+	PatchDir string
+
+	// The version of the created patch.
+	Version string
+
+	// If true, resources which exist within Pristine, but not within Modified, are removed by the patch.
+	Remove bool
+
+	// If true, the patch depends on the current version of the summary.json, along with each of
+	// that version's dependencies.
+	DependOnPrevious bool
+
+	// If true, the summary.json's current version is not changed to Version.
+	KeepCurrent bool
+}
+
+// The changes made by a created patch, where each path is a client resource.
+type Result struct {
+	Patch *patch.Tpp
+
+	Replaced []string
+	Added    []string
+	Removed  []string
+
+	// The total size, in bytes, of the patch's resources.
+	Size int64
+}
+
+// A regular file within a client directory.
+type clientFile struct {
+	path string
+	size int64
+}
+
+// Returns the regular files within dir, mapped by their slash separated paths relative to dir.
+func clientFiles(dir string) (map[string]clientFile, error) {
+	files := make(map[string]clientFile)
+
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			log.Printf("Skipping \"%s\": not a regular file", name)
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relativePath)] = clientFile{path: name, size: info.Size()}
+		return nil
+	})
+
+	return files, err
+}
+
+func hashFile(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func copyFile(source, destination string) error {
+	err := os.MkdirAll(filepath.Dir(destination), 0755)
+	if err != nil {
+		return err
+	}
+
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	defer destinationFile.Close()
+
+	_, err = io.Copy(destinationFile, sourceFile)
+	return err
+}
+
+// Returns the summary.json within the Server Patch Directory, or an empty summary if the file does
+// not exist.
+func readSummary(patchDir string) (patch.Summary, error) {
+	summary := patch.Summary{AvailableVersions: []string{}}
+
+	data, err := os.ReadFile(filepath.Join(patchDir, "summary.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return summary, nil
+	}
+
+	if err != nil {
+		return summary, fmt.Errorf("could not read summary.json: %w", err)
+	}
+
+	err = json.Unmarshal(data, &summary)
+	if err != nil {
+		return summary, fmt.Errorf("could not unmarshal summary.json: %w", err)
+	}
+
+	return summary, nil
+}
+
+func writeJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(name, append(data, '\n'), 0755)
+}
+
+// Reads the patch.json of the version within the Server Patch Directory, and checks that the pristine
+// client was produced by the version: each resource replaced or added by the version must match the
+// sha256 of its download, and each resource removed by the version must not exist. Resources whose
+// downloads do not specify a sha256 are not checked.
+//
+// Returns the resources which are changed by the version.
+func checkPreviousClient(patchDir, version string, pristineFiles map[string]clientFile) (map[string]bool, error) {
+	data, err := os.ReadFile(filepath.Join(patchDir, version, "patch.json"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read previous version: %w", err)
+	}
+
+	previous := patch.NewTpp(version).(*patch.Tpp)
+	if err := json.Unmarshal(data, previous); err != nil {
+		return nil, fmt.Errorf("could not unmarshal previous version: %w", err)
+	}
+
+	// Maps the name of each download to its sha256
+	sums := make(map[string]string)
+	for remotePath, entry := range previous.Download {
+		name := entry.Name
+		if len(name) == 0 {
+			name = path.Base(remotePath)
+		}
+		sums[path.Clean(name)] = entry.SHA256
+	}
+
+	changed := make(map[string]bool)
+	notProduced := func(resource string) error {
+		return fmt.Errorf("pristine client was not produced by the previous version \"%s\": \"%s\" does not match its changes", version, resource)
+	}
+
+	for _, transfers := range []map[string]string{previous.Replace, previous.Add} {
+		for source, destination := range transfers {
+			resource := path.Clean(filepath.ToSlash(destination))
+			changed[resource] = true
+
+			sum := sums[path.Clean(filepath.ToSlash(source))]
+			if len(sum) == 0 {
+				continue
+			}
+
+			file, ok := pristineFiles[resource]
+			if !ok {
+				return nil, notProduced(resource)
+			}
+
+			pristineSum, err := hashFile(file.path)
+			if err != nil {
+				return nil, fmt.Errorf("could not hash \"%s\": %w", file.path, err)
+			}
+
+			if pristineSum != sum {
+				return nil, notProduced(resource)
+			}
+		}
+	}
+
+	for _, resource := range previous.Remove {
+		resource = path.Clean(filepath.ToSlash(resource))
+		changed[resource] = true
+
+		if _, ok := pristineFiles[resource]; ok {
+			return nil, notProduced(resource)
+		}
+	}
+
+	return changed, nil
+}
+
+// Compares the pristine and modified client directories, and creates a version directory for the patch
+// within the Server Patch Directory. The version directory contains a copy of each modified and new file
+// within its resources directory, as well as a patch.json which downloads the files, along with their
+// hashes and sizes, and then replaces or adds the client resources.
+//
+// The version is added to the summary.json within the Server Patch Directory, which is created if it
+// does not exist, and set as the current version unless options.KeepCurrent is true.
+//
+// If options.DependOnPrevious is true, the pristine client must be the client produced by the summary.json's
+// current version, otherwise an error is returned. The patch takes precedence over the current version for
+// each of the current version's resources which it changes again.
+//
+// The version directory is built within a temporary directory and moved into place once it is complete,
+// so if MakePatch fails, the Server Patch Directory is left unchanged.
+func MakePatch(options Options) (result Result, err error) {
+	if err := patch.ValidateVersionName(options.Version); err != nil {
+		return Result{}, err
+	}
+
+	versionDir := filepath.Join(options.PatchDir, options.Version)
+	if _, err := os.Stat(versionDir); err == nil {
+		return Result{}, fmt.Errorf("version directory \"%s\" already exists", versionDir)
+	}
+
+	summary, err := readSummary(options.PatchDir)
+	if err != nil {
+		return Result{}, err
+	}
+
+	pristineFiles, err := clientFiles(options.Pristine)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not read pristine client: %w", err)
+	}
+
+	modifiedFiles, err := clientFiles(options.Modified)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not read modified client: %w", err)
+	}
+
+	// The resources changed by the previous version, which the patch may override
+	previouslyChanged := make(map[string]bool)
+
+	if options.DependOnPrevious {
+		if len(summary.CurrentVersion) == 0 {
+			return Result{}, errors.New("cannot depend on the previous version: summary.json has no current version")
+		}
+
+		previouslyChanged, err = checkPreviousClient(options.PatchDir, summary.CurrentVersion, pristineFiles)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	tpp := patch.NewTpp(options.Version).(*patch.Tpp)
+	tpp.Download = make(map[string]patch.DownloadEntry)
+	tpp.Replace = make(map[string]string)
+	tpp.Add = make(map[string]string)
+
+	result = Result{
+		Patch:    tpp,
+		Replaced: []string{},
+		Added:    []string{},
+		Removed:  []string{},
+	}
+
+	resources := []string{}
+	for resource := range modifiedFiles {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	for _, resource := range resources {
+		modified := modifiedFiles[resource]
+
+		sum, err := hashFile(modified.path)
+		if err != nil {
+			return Result{}, fmt.Errorf("could not hash \"%s\": %w", modified.path, err)
+		}
+
+		pristine, exists := pristineFiles[resource]
+		if exists && pristine.size == modified.size {
+			pristineSum, err := hashFile(pristine.path)
+			if err != nil {
+				return Result{}, fmt.Errorf("could not hash \"%s\": %w", pristine.path, err)
+			}
+
+			if sum == pristineSum {
+				continue
+			}
+		}
+
+		name := path.Join(resourcesDir, resource)
+		tpp.Download[path.Join("/", options.Version, name)] = patch.DownloadEntry{
+			Name:   name,
+			SHA256: sum,
+			Size:   modified.size,
+		}
+
+		if exists {
+			tpp.Replace[name] = resource
+			result.Replaced = append(result.Replaced, resource)
+		} else {
+			tpp.Add[name] = resource
+			result.Added = append(result.Added, resource)
+		}
+
+		result.Size += modified.size
+	}
+
+	if options.Remove {
+		for resource := range pristineFiles {
+			if _, ok := modifiedFiles[resource]; !ok {
+				result.Removed = append(result.Removed, resource)
+			}
+		}
+		sort.Strings(result.Removed)
+		tpp.Remove = result.Removed
+	}
+
+	if len(tpp.Download) == 0 && len(tpp.Remove) == 0 {
+		return Result{}, errors.New("the pristine and modified clients are identical")
+	}
+
+	if options.DependOnPrevious {
+		tpp.Dependencies = []string{summary.CurrentVersion + "*"}
+
+		for _, resource := range append(append([]string{}, result.Replaced...), result.Removed...) {
+			if previouslyChanged[resource] {
+				tpp.Precedence = []string{summary.CurrentVersion}
+				break
+			}
+		}
+	}
+
+	err = os.MkdirAll(options.PatchDir, 0755)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not create patch directory: %w", err)
+	}
+
+	// The temporary directory is not a valid version, so it is never advertised by a generated summary.json
+	tempDir, err := os.MkdirTemp(options.PatchDir, fmt.Sprintf(".%s-*.tmp", options.Version))
+	if err != nil {
+		return Result{}, fmt.Errorf("could not create version directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, resource := range append(append([]string{}, result.Replaced...), result.Added...) {
+		err := copyFile(modifiedFiles[resource].path, filepath.Join(tempDir, resourcesDir, filepath.FromSlash(resource)))
+		if err != nil {
+			return Result{}, fmt.Errorf("could not copy resource \"%s\": %w", resource, err)
+		}
+	}
+
+	err = writeJSON(filepath.Join(tempDir, "patch.json"), tpp)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not write patch.json: %w", err)
+	}
+
+	err = os.Chmod(tempDir, 0755)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not create version directory: %w", err)
+	}
+
+	err = os.Rename(tempDir, versionDir)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not create version directory: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			os.RemoveAll(versionDir)
+		}
+	}()
+
+	available := false
+	for _, version := range summary.AvailableVersions {
+		available = available || version == options.Version
+	}
+
+	if !available {
+		summary.AvailableVersions = append(summary.AvailableVersions, options.Version)
+		sort.SliceStable(summary.AvailableVersions, func(i, j int) bool {
+			return patch.CompareVersions(summary.AvailableVersions[i], summary.AvailableVersions[j]) < 0
+		})
+	}
+
+	if !options.KeepCurrent || len(summary.CurrentVersion) == 0 {
+		summary.CurrentVersion = options.Version
+	}
+
+	err = writeJSON(filepath.Join(options.PatchDir, "summary.json"), summary)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not write summary.json: %w", err)
+	}
+
+	return result, nil
+}
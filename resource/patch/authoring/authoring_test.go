@@ -0,0 +1,271 @@
+package authoring_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/I-Am-Dench/nimbus-launcher/ldf"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch/authoring"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
+)
+
+type fileSystem map[string][]byte
+
+func (fs fileSystem) Init(dir string, t *testing.T) {
+	t.Helper()
+
+	for relativePath, data := range fs {
+		path := filepath.Join(dir, filepath.FromSlash(relativePath))
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("init fs: %v", err)
+		}
+
+		err = os.WriteFile(path, data, 0755)
+		if err != nil {
+			t.Fatalf("init fs: %v", err)
+		}
+	}
+}
+
+func pristineFileSystem() fileSystem {
+	return fileSystem{
+		"data/file1": []byte("default data 1"),
+		"data/file2": []byte("default data 2"),
+		"data/file3": []byte("default data 3"),
+	}
+}
+
+// Returns the names of the entries within dir, sorted.
+func entries(t *testing.T, dir string) []string {
+	t.Helper()
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+
+	names := []string{}
+	for _, entry := range dirEntries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Reads the version's patch.json from the Server Patch Directory, and checks it for problems, including
+// the size and hash of each of its downloads.
+func readPatch(t *testing.T, patchDir, version string) *patch.Tpp {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(patchDir, version, "patch.json"))
+	if err != nil {
+		t.Fatalf("read patch: %v", err)
+	}
+
+	tpp := patch.NewTpp(version).(*patch.Tpp)
+	if err := json.Unmarshal(data, tpp); err != nil {
+		t.Fatalf("read patch: %v", err)
+	}
+
+	problems := patch.Lint(tpp, patch.LintOptions{
+		Read: func(path string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(patchDir, filepath.FromSlash(path)))
+		},
+		VerifyDownloads: true,
+	})
+
+	for _, problem := range problems {
+		t.Errorf("%s: %v", version, problem)
+	}
+
+	return tpp
+}
+
+func TestMakePatch(t *testing.T) {
+	dir := t.TempDir()
+
+	pristine := filepath.Join(dir, "pristine")
+	pristineFileSystem().Init(pristine, t)
+
+	modified := filepath.Join(dir, "modified")
+	fileSystem{
+		"data/file1":     []byte("default data 1"),
+		"data/file2":     []byte("Test 2"),
+		"data/file3":     []byte("default data 3"),
+		"data/new/file4": []byte("Test 4"),
+	}.Init(modified, t)
+
+	patchDir := filepath.Join(dir, "remote")
+
+	result, err := authoring.MakePatch(authoring.Options{
+		Pristine: pristine,
+		Modified: modified,
+		PatchDir: patchDir,
+		Version:  "v1.0.0",
+	})
+	if err != nil {
+		t.Fatalf("test make patch: v1.0.0: %v", err)
+	}
+
+	if strings.Join(result.Replaced, " ") != "data/file2" || strings.Join(result.Added, " ") != "data/new/file4" || len(result.Removed) != 0 {
+		t.Errorf("test make patch: v1.0.0: expected \"data/file2\" to be replaced and \"data/new/file4\" to be added but got %v and %v", result.Replaced, result.Added)
+	}
+
+	tpp := readPatch(t, patchDir, "v1.0.0")
+	if tpp.Replace["resources/data/file2"] != "data/file2" || tpp.Add["resources/data/new/file4"] != "data/new/file4" {
+		t.Errorf("test make patch: v1.0.0: unexpected directives: replace %v, add %v", tpp.Replace, tpp.Add)
+	}
+
+	// The second patch is made against the first patch's client
+	secondModified := filepath.Join(dir, "modified2")
+	fileSystem{
+		"data/file1":     []byte("Test 1"),
+		"data/file2":     []byte("Test 2"),
+		"data/new/file4": []byte("Test 4 (updated)"),
+	}.Init(secondModified, t)
+
+	_, err = authoring.MakePatch(authoring.Options{
+		Pristine:         pristine,
+		Modified:         secondModified,
+		PatchDir:         patchDir,
+		Version:          "v1.1.0",
+		Remove:           true,
+		DependOnPrevious: true,
+	})
+	if err == nil {
+		t.Errorf("test make patch: v1.1.0: expected an error for a client which was not produced by v1.0.0")
+	}
+
+	result, err = authoring.MakePatch(authoring.Options{
+		Pristine:         modified,
+		Modified:         secondModified,
+		PatchDir:         patchDir,
+		Version:          "v1.1.0",
+		Remove:           true,
+		DependOnPrevious: true,
+	})
+	if err != nil {
+		t.Fatalf("test make patch: v1.1.0: %v", err)
+	}
+
+	if len(result.Removed) != 1 || result.Removed[0] != "data/file3" {
+		t.Errorf("test make patch: v1.1.0: expected \"data/file3\" to be removed but got %v", result.Removed)
+	}
+
+	second := readPatch(t, patchDir, "v1.1.0")
+	if strings.Join(second.Dependencies, " ") != "v1.0.0*" {
+		t.Errorf("test make patch: v1.1.0: expected to depend on \"v1.0.0*\" but got %v", second.Dependencies)
+	}
+
+	if strings.Join(second.Precedence, " ") != "v1.0.0" {
+		t.Errorf("test make patch: v1.1.0: expected to take precedence over \"v1.0.0\" but got %v", second.Precedence)
+	}
+
+	serv := server.New(server.Config{DownloadDir: filepath.Join(dir, "downloads"), Config: ldf.DefaultBootConfig()})
+	conflicts, err := patch.FindConflicts(serv, []patch.Patch{tpp, second})
+	if err != nil {
+		t.Errorf("test make patch: v1.1.0: %v", err)
+	}
+
+	// v1.1.0 replaces "data/new/file4", which is added by v1.0.0
+	if len(conflicts) != 1 || !conflicts[0].Declared {
+		t.Errorf("test make patch: v1.1.0: expected a single declared conflict but got %v", conflicts)
+	}
+
+	_, err = authoring.MakePatch(authoring.Options{
+		Pristine: secondModified,
+		Modified: secondModified,
+		PatchDir: patchDir,
+		Version:  "v1.2.0",
+	})
+	if err == nil {
+		t.Errorf("test make patch: expected an error for identical clients")
+	}
+
+	_, err = authoring.MakePatch(authoring.Options{
+		Pristine: pristine,
+		Modified: modified,
+		PatchDir: patchDir,
+		Version:  "v1.1.0",
+	})
+	if err == nil {
+		t.Errorf("test make patch: expected an error for an existing version")
+	}
+
+	data, err := os.ReadFile(filepath.Join(patchDir, "summary.json"))
+	if err != nil {
+		t.Fatalf("test make patch: %v", err)
+	}
+
+	summary := patch.Summary{}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("test make patch: %v", err)
+	}
+
+	if summary.CurrentVersion != "v1.1.0" || strings.Join(summary.AvailableVersions, " ") != "v1.0.0 v1.1.0" {
+		t.Errorf("test make patch: unexpected summary: %+v", summary)
+	}
+
+	if names := strings.Join(entries(t, patchDir), " "); names != "summary.json v1.0.0 v1.1.0" {
+		t.Errorf("test make patch: expected only the summary.json and version directories but got \"%s\"", names)
+	}
+}
+
+func TestFailedMakePatch(t *testing.T) {
+	dir := t.TempDir()
+
+	pristine := filepath.Join(dir, "pristine")
+	pristineFileSystem().Init(pristine, t)
+
+	modified := filepath.Join(dir, "modified")
+	fileSystem{
+		"data/file1": []byte("Test 1"),
+	}.Init(modified, t)
+
+	patchDir := filepath.Join(dir, "remote")
+	if err := os.MkdirAll(patchDir, 0755); err != nil {
+		t.Fatalf("test failed make patch: %v", err)
+	}
+
+	// The summary.json links to a directory which does not exist, so it is read as missing but
+	// cannot be written once the version directory is complete
+	summaryPath := filepath.Join(patchDir, "summary.json")
+	if err := os.Symlink(filepath.Join(dir, "missing", "summary.json"), summaryPath); err != nil {
+		t.Skipf("test failed make patch: %v", err)
+	}
+
+	options := authoring.Options{
+		Pristine: pristine,
+		Modified: modified,
+		PatchDir: patchDir,
+		Version:  "v1.0.0",
+	}
+
+	_, err := authoring.MakePatch(options)
+	if err == nil {
+		t.Fatal("test failed make patch: expected an error when the summary.json cannot be written")
+	}
+
+	if names := strings.Join(entries(t, patchDir), " "); names != "summary.json" {
+		t.Errorf("test failed make patch: expected the patch directory to be unchanged but got \"%s\"", names)
+	}
+
+	if err := os.Remove(summaryPath); err != nil {
+		t.Fatalf("test failed make patch: %v", err)
+	}
+
+	_, err = authoring.MakePatch(options)
+	if err != nil {
+		t.Fatalf("test failed make patch: rerun: %v", err)
+	}
+
+	readPatch(t, patchDir, "v1.0.0")
+}
@@ -0,0 +1,221 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// The name of the file, within a version's download directory, which the conditions of the download are saved to.
+const conditionsFile = "conditions.json"
+
+// The platform and locale which conditional directives are evaluated against.
+type Conditions struct {
+	// The runtime.GOOS of the launcher, e.g. "windows" or "linux".
+	OS string `json:"os"`
+
+	// The LOCALE of the server's boot.cfg, e.g. "en_US".
+	Locale string `json:"locale"`
+}
+
+// Returns the conditions of the launcher's platform and the server's current boot.cfg.
+func ConditionsOf(server Server) Conditions {
+	conditions := Conditions{OS: runtime.GOOS}
+	if config := server.BootConfig(); config != nil {
+		conditions.Locale = config.Locale
+	}
+	return conditions
+}
+
+// Returns the conditions which the version was downloaded with. If the version has not been downloaded,
+// the current conditions of the server are returned.
+//
+// The conditions are saved with the download, since the patch may change the LOCALE of the server's
+// boot.cfg, and the blocks which were downloaded must also be the blocks which are transferred.
+func conditionsFor(server Server, version string) Conditions {
+	path := filepath.Join(server.DownloadDir(), version, conditionsFile)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ConditionsOf(server)
+	}
+
+	conditions := Conditions{}
+	if err := json.Unmarshal(data, &conditions); err != nil {
+		log.Printf("Could not read saved conditions \"%s\": %v", path, err)
+		return ConditionsOf(server)
+	}
+
+	return conditions
+}
+
+// Saves the conditions which the version is downloaded with. See conditionsFor.
+func saveConditions(server Server, version string, conditions Conditions) error {
+	data, err := json.MarshalIndent(conditions, "", "    ")
+	if err != nil {
+		return fmt.Errorf("could not marshal conditions: %w", err)
+	}
+
+	path := filepath.Join(server.DownloadDir(), version, conditionsFile)
+	if err := os.WriteFile(path, data, 0755); err != nil {
+		return fmt.Errorf("could not save conditions \"%s\": %w", path, err)
+	}
+
+	return nil
+}
+
+func (conditions Conditions) String() string {
+	return fmt.Sprintf("os=%s, locale=%s", conditions.OS, conditions.Locale)
+}
+
+// A block of directives which is merged into the patch's directives only if each of its conditions match.
+// Entries within the block take precedence over the patch's entries with the same key, and over the patch's
+// entries which change the same client resource.
+type Conditional struct {
+	// The runtime.GOOS values the block applies to. If empty, the block applies to every platform.
+	OS []string `json:"os,omitempty"`
+
+	// The boot.cfg LOCALE values the block applies to. If empty, the block applies to every locale.
+	Locale []string `json:"locale,omitempty"`
+
+	Directives
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if the block applies to the conditions.
+func (conditional Conditional) Matches(conditions Conditions) bool {
+	return matchesAny(conditional.OS, conditions.OS) && matchesAny(conditional.Locale, conditions.Locale)
+}
+
+func mergeMaps[V any](a, b map[string]V) map[string]V {
+	if len(b) == 0 {
+		return a
+	}
+
+	merged := make(map[string]V, len(a)+len(b))
+	for key, value := range a {
+		merged[key] = value
+	}
+	for key, value := range b {
+		merged[key] = value
+	}
+	return merged
+}
+
+// Returns the client resources changed by the directives' replace, delta, add, and remove entries.
+func (directives Directives) destinations() map[string]bool {
+	destinations := make(map[string]bool)
+	for _, destination := range directives.Replace {
+		destinations[filepath.Clean(destination)] = true
+	}
+	for _, entry := range directives.Delta {
+		destinations[filepath.Clean(entry.Path)] = true
+	}
+	for _, destination := range directives.Add {
+		destinations[filepath.Clean(destination)] = true
+	}
+	for _, resource := range directives.Remove {
+		destinations[filepath.Clean(resource)] = true
+	}
+	return destinations
+}
+
+// Returns the entries of m whose destination is not within overridden.
+func withoutDestinations[V any](m map[string]V, destination func(V) string, overridden map[string]bool) map[string]V {
+	if len(overridden) == 0 {
+		return m
+	}
+
+	kept := make(map[string]V, len(m))
+	for key, value := range m {
+		if !overridden[filepath.Clean(destination(value))] {
+			kept[key] = value
+		}
+	}
+	return kept
+}
+
+// Returns the directives with other merged into them, where the entries of other take precedence. The
+// entries of the directives which change a client resource that is also changed by other are dropped.
+func (directives Directives) merge(other Directives) Directives {
+	overridden := other.destinations()
+
+	remove := []string{}
+	for _, resource := range directives.Remove {
+		if !overridden[filepath.Clean(resource)] {
+			remove = append(remove, resource)
+		}
+	}
+	remove = append(remove, other.Remove...)
+
+	if len(remove) == 0 {
+		remove = nil
+	}
+
+	destination := func(path string) string { return path }
+
+	return Directives{
+		Download: mergeMaps(directives.Download, other.Download),
+		Extract:  mergeMaps(directives.Extract, other.Extract),
+		Replace:  mergeMaps(withoutDestinations(directives.Replace, destination, overridden), other.Replace),
+		Add:      mergeMaps(withoutDestinations(directives.Add, destination, overridden), other.Add),
+		Remove:   remove,
+		Delta:    mergeMaps(withoutDestinations(directives.Delta, func(entry DeltaEntry) string { return entry.Path }, overridden), other.Delta),
+	}
+}
+
+// Returns the patch with each conditional block which matches the conditions merged into its directives,
+// in the order the blocks are listed. The returned patch has no conditional blocks.
+func (patch *Tpp) Effective(conditions Conditions) *Tpp {
+	if len(patch.When) == 0 {
+		return patch
+	}
+
+	effective := *patch
+	effective.When = nil
+
+	for _, conditional := range patch.When {
+		if conditional.Matches(conditions) {
+			effective.Directives = effective.Directives.merge(conditional.Directives)
+		}
+	}
+
+	return &effective
+}
+
+// Returns the directives of each of the patch's conditional blocks, whether or not they match.
+func (patch *Tpp) conditionalDirectives() []Directives {
+	directives := []Directives{}
+	for _, conditional := range patch.When {
+		directives = append(directives, conditional.Directives)
+	}
+	return directives
+}
+
+// Returns the number of conditional blocks which match the conditions.
+func (patch *Tpp) applicable(conditions Conditions) int {
+	count := 0
+	for _, conditional := range patch.When {
+		if conditional.Matches(conditions) {
+			count++
+		}
+	}
+	return count
+}
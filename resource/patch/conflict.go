@@ -0,0 +1,135 @@
+package patch
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// A client resource which is changed by two different versions within a resolved dependency set, where
+// the later version's change overrides the earlier version's change.
+type Conflict struct {
+	// The client resource, relative to the client directory.
+	Path string `json:"path"`
+
+	// The earlier version, and the directive which changes the resource.
+	Version   string `json:"version"`
+	Directive string `json:"directive"`
+
+	// The later version, and the directive which overrides the earlier change.
+	OverriddenBy        string `json:"overriddenBy"`
+	OverridingDirective string `json:"overridingDirective"`
+
+	// True if the later version declares precedence over the earlier version.
+	Declared bool `json:"declared"`
+}
+
+// Returns true if the conflicting changes cannot both be applied, i.e. the later version adds a
+// resource which the earlier version already created or replaced without declaring precedence, or
+// the later version changes a resource which the earlier version removed.
+func (conflict Conflict) Fatal() bool {
+	if conflict.Directive == "remove" {
+		return conflict.OverridingDirective == "replace" || conflict.OverridingDirective == "delta"
+	}
+
+	return conflict.OverridingDirective == "add" && !conflict.Declared
+}
+
+func (conflict Conflict) String() string {
+	note := ""
+	if conflict.Declared {
+		note = " (declared)"
+	}
+
+	return fmt.Sprintf("%s: [%s] %s is overridden by [%s] %s%s", filepath.ToSlash(conflict.Path), conflict.Version, conflict.Directive, conflict.OverriddenBy, conflict.OverridingDirective, note)
+}
+
+// Patches which implement Overrider can declare precedence over the changes made by other versions
+// within the same dependency set.
+type Overrider interface {
+	// Returns true if the patch's changes intentionally override the changes made by version.
+	Overrides(version string) bool
+}
+
+// Returns the conflicts between the transfers, where the transfers are in the order they are applied, and
+// patches are the patches which planned them.
+func findConflicts(transfers []PlannedTransfer, patches []Patch) []Conflict {
+	overriders := make(map[string]Overrider)
+	for _, p := range patches {
+		if overrider, ok := p.(Overrider); ok {
+			overriders[p.Version()] = overrider
+		}
+	}
+
+	conflicts := []Conflict{}
+	changes := make(map[string]PlannedTransfer)
+
+	for _, transfer := range transfers {
+		previous, ok := changes[transfer.Path]
+		changes[transfer.Path] = transfer
+
+		if !ok || previous.Version == transfer.Version {
+			continue
+		}
+
+		overrider, ok := overriders[transfer.Version]
+		conflicts = append(conflicts, Conflict{
+			Path:                transfer.Path,
+			Version:             previous.Version,
+			Directive:           previous.Directive,
+			OverriddenBy:        transfer.Version,
+			OverridingDirective: transfer.Directive,
+			Declared:            ok && overrider.Overrides(previous.Version),
+		})
+	}
+
+	return conflicts
+}
+
+// Returns the conflicts between the changes made by each of the patches, where patches are in the
+// order they are applied, e.g. a patch's resolved dependencies followed by the patch. The conditional
+// directives of each patch are evaluated against the conditions the patch was downloaded with, or the
+// current conditions of the server if it has not been downloaded. Patches which do not implement
+// TransferPlanner are not checked.
+//
+// If any of the conflicts are fatal, ErrConflict is returned along with the conflicts.
+func FindConflicts(server Server, patches []Patch) ([]Conflict, error) {
+	plan := &Plan{
+		Transfers:  []PlannedTransfer{},
+		Conditions: ConditionsOf(server),
+		server:     server,
+		resources:  make(map[string]bool),
+	}
+
+	for _, p := range patches {
+		planner, ok := p.(TransferPlanner)
+		if !ok {
+			continue
+		}
+
+		plan.Versions = append(plan.Versions, p.Version())
+
+		err := planner.PlanTransfers(plan)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Version(), err)
+		}
+	}
+
+	conflicts := findConflicts(plan.Transfers, patches)
+
+	fatal := []string{}
+	for _, conflict := range conflicts {
+		if conflict.Fatal() {
+			fatal = append(fatal, conflict.String())
+		} else if !conflict.Declared {
+			log.Printf("Undeclared conflict: %s", conflict)
+		}
+	}
+
+	if len(fatal) > 0 {
+		return conflicts, fmt.Errorf("%w: %s", ErrConflict, strings.Join(fatal, "; "))
+	}
+
+	return conflicts, nil
+}
@@ -0,0 +1,284 @@
+// Package delta creates and applies binary deltas between two versions of a resource.
+//
+// A delta is a sequence of instructions which rebuild the target from the source, in the
+// style of VCDIFF: COPY instructions copy a range of bytes from the source, and ADD instructions
+// insert literal bytes which do not appear within the source. Each delta has the format:
+//
+//	"NLDELTA1" gzip(uvarint(sourceSize) uvarint(targetSize) instructions...)
+//
+// where each instruction is either:
+//
+//	0x01 uvarint(offset) uvarint(length)  // COPY
+//	0x02 uvarint(length) bytes...         // ADD
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const Magic = "NLDELTA1"
+
+const (
+	opCopy = byte(0x01)
+	opAdd  = byte(0x02)
+)
+
+const (
+	// The size of the source blocks which are indexed when creating a delta. Matches shorter
+	// than a block are written as literal bytes.
+	blockSize = 32
+
+	// The maximum number of source offsets indexed for a single block hash.
+	maxCandidates = 8
+
+	hashBase = uint32(16777619)
+
+	// The maximum size of a target which Patch rebuilds. Deltas which declare a larger target are invalid.
+	MaxTargetSize = 1 << 30
+
+	// The maximum number of bytes preallocated for the target before any instructions are read.
+	maxPreallocate = 1 << 20
+)
+
+var (
+	ErrInvalidDelta   = errors.New("invalid delta")
+	ErrSourceMismatch = errors.New("delta source mismatch")
+)
+
+func hashBlock(block []byte) uint32 {
+	h := uint32(0)
+	for _, b := range block {
+		h = h*hashBase + uint32(b)
+	}
+	return h
+}
+
+// The value of hashBase^(blockSize-1), used to remove the leading byte from a rolling hash.
+var hashPower = func() uint32 {
+	p := uint32(1)
+	for i := 0; i < blockSize-1; i++ {
+		p *= hashBase
+	}
+	return p
+}()
+
+func rollHash(h uint32, out, in byte) uint32 {
+	return (h-uint32(out)*hashPower)*hashBase + uint32(in)
+}
+
+type index map[uint32][]int
+
+func newIndex(source []byte) index {
+	index := make(index)
+	for offset := 0; offset+blockSize <= len(source); offset += blockSize {
+		h := hashBlock(source[offset : offset+blockSize])
+		if len(index[h]) < maxCandidates {
+			index[h] = append(index[h], offset)
+		}
+	}
+	return index
+}
+
+// Returns the longest match between the source and the block of the target starting at i, extending
+// the match backwards no further than floor.
+func (index index) match(source, target []byte, i, floor int, h uint32) (start, offset, length int, ok bool) {
+	for _, candidate := range index[h] {
+		if !bytes.Equal(source[candidate:candidate+blockSize], target[i:i+blockSize]) {
+			continue
+		}
+
+		n := blockSize
+		for i+n < len(target) && candidate+n < len(source) && target[i+n] == source[candidate+n] {
+			n++
+		}
+
+		back := 0
+		for i-back > floor && candidate-back > 0 && target[i-back-1] == source[candidate-back-1] {
+			back++
+		}
+
+		if n+back > length {
+			start, offset, length, ok = i-back, candidate-back, n+back, true
+		}
+	}
+
+	return start, offset, length, ok
+}
+
+type writer struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *writer) uvarint(v int) {
+	n := binary.PutUvarint(w.buf[:], uint64(v))
+	w.w.Write(w.buf[:n])
+}
+
+func (w *writer) add(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	w.w.WriteByte(opAdd)
+	w.uvarint(len(data))
+	w.w.Write(data)
+}
+
+func (w *writer) copy(offset, length int) {
+	w.w.WriteByte(opCopy)
+	w.uvarint(offset)
+	w.uvarint(length)
+}
+
+// Returns a delta which rebuilds target from source.
+func Diff(source, target []byte) ([]byte, error) {
+	out := bytes.Buffer{}
+	out.WriteString(Magic)
+
+	compressor, err := gzip.NewWriterLevel(&out, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &writer{w: bufio.NewWriter(compressor)}
+	w.uvarint(len(source))
+	w.uvarint(len(target))
+
+	index := newIndex(source)
+
+	literal := 0
+	i := 0
+	h, hashed := uint32(0), false
+	for i+blockSize <= len(target) {
+		if !hashed {
+			h, hashed = hashBlock(target[i:i+blockSize]), true
+		}
+
+		if start, offset, length, ok := index.match(source, target, i, literal, h); ok {
+			w.add(target[literal:start])
+			w.copy(offset, length)
+
+			i = start + length
+			literal, hashed = i, false
+			continue
+		}
+
+		if i+blockSize < len(target) {
+			h = rollHash(h, target[i], target[i+blockSize])
+		}
+		i++
+	}
+	w.add(target[literal:])
+
+	if err := w.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	if err := compressor.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func readUvarint(r io.ByteReader) (int, error) {
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidDelta, err)
+	}
+
+	if v > uint64(int(^uint(0)>>1)) {
+		return 0, fmt.Errorf("%w: value out of range", ErrInvalidDelta)
+	}
+
+	return int(v), nil
+}
+
+// Rebuilds the target from source and a delta created by Diff.
+//
+// Returns ErrSourceMismatch if source is not the same size as the source the delta was created from.
+func Patch(source, delta []byte) ([]byte, error) {
+	if !bytes.HasPrefix(delta, []byte(Magic)) {
+		return nil, fmt.Errorf("%w: missing magic", ErrInvalidDelta)
+	}
+
+	decompressor, err := gzip.NewReader(bytes.NewReader(delta[len(Magic):]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDelta, err)
+	}
+	defer decompressor.Close()
+
+	r := bufio.NewReader(decompressor)
+
+	sourceSize, err := readUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if sourceSize != len(source) {
+		return nil, fmt.Errorf("%w: expected %d byte(s) but got %d", ErrSourceMismatch, sourceSize, len(source))
+	}
+
+	targetSize, err := readUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetSize > MaxTargetSize {
+		return nil, fmt.Errorf("%w: target size %d exceeds the maximum of %d", ErrInvalidDelta, targetSize, MaxTargetSize)
+	}
+
+	// The target size is untrusted, so the target only grows as instructions are read
+	target := make([]byte, 0, min(targetSize, maxPreallocate))
+	for len(target) < targetSize {
+		op, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDelta, err)
+		}
+
+		switch op {
+		case opCopy:
+			offset, err := readUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+
+			length, err := readUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+
+			if offset > len(source) || length > len(source)-offset || length > targetSize-len(target) {
+				return nil, fmt.Errorf("%w: copy out of range", ErrInvalidDelta)
+			}
+
+			target = append(target, source[offset:offset+length]...)
+		case opAdd:
+			length, err := readUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+
+			if length > targetSize-len(target) {
+				return nil, fmt.Errorf("%w: add out of range", ErrInvalidDelta)
+			}
+
+			buffer := bytes.NewBuffer(target)
+			if _, err := io.CopyN(buffer, r, int64(length)); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidDelta, err)
+			}
+			target = buffer.Bytes()
+		default:
+			return nil, fmt.Errorf("%w: unknown instruction 0x%02x", ErrInvalidDelta, op)
+		}
+	}
+
+	return target, nil
+}
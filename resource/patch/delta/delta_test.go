@@ -0,0 +1,118 @@
+package delta_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch/delta"
+)
+
+func randomBytes(r *rand.Rand, n int) []byte {
+	data := make([]byte, n)
+	r.Read(data)
+	return data
+}
+
+func testRoundTrip(t *testing.T, name string, source, target []byte) []byte {
+	t.Helper()
+
+	d, err := delta.Diff(source, target)
+	if err != nil {
+		t.Fatalf("%s: diff: %v", name, err)
+	}
+
+	patched, err := delta.Patch(source, d)
+	if err != nil {
+		t.Fatalf("%s: patch: %v", name, err)
+	}
+
+	if !bytes.Equal(patched, target) {
+		t.Fatalf("%s: patched target does not match target", name)
+	}
+
+	t.Logf("%s: %d byte source; %d byte target; %d byte delta", name, len(source), len(target), len(d))
+	return d
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	source := randomBytes(r, 256*1024)
+
+	// A single modified byte
+	target := bytes.Clone(source)
+	target[100000] ^= 0xff
+	d := testRoundTrip(t, "modified byte", source, target)
+	if len(d) > 1024 {
+		t.Errorf("modified byte: expected a small delta but got %d byte(s)", len(d))
+	}
+
+	// Inserted and removed ranges
+	target = append(bytes.Clone(source[:5000]), randomBytes(r, 777)...)
+	target = append(target, source[9000:200000]...)
+	target = append(target, source[220000:]...)
+	d = testRoundTrip(t, "inserted and removed", source, target)
+	if len(d) > 4096 {
+		t.Errorf("inserted and removed: expected a small delta but got %d byte(s)", len(d))
+	}
+
+	// Moved blocks
+	target = append(bytes.Clone(source[128*1024:]), source[:128*1024]...)
+	testRoundTrip(t, "moved", source, target)
+
+	testRoundTrip(t, "empty source", []byte{}, randomBytes(r, 1000))
+	testRoundTrip(t, "empty target", source, []byte{})
+	testRoundTrip(t, "short", []byte("default data 1"), []byte("default data 1 (patched)"))
+}
+
+// Returns a delta which only contains the source and target sizes, and no instructions.
+func deltaWithHeader(sourceSize, targetSize uint64) []byte {
+	buffer := bytes.NewBufferString(delta.Magic)
+
+	compressor := gzip.NewWriter(buffer)
+	compressor.Write(binary.AppendUvarint(binary.AppendUvarint(nil, sourceSize), targetSize))
+	compressor.Close()
+
+	return buffer.Bytes()
+}
+
+func TestInvalidDeltas(t *testing.T) {
+	source := []byte("the pristine client resource, which is longer than a single block")
+	target := []byte("the patched client resource, which is longer than a single block!")
+
+	d, err := delta.Diff(source, target)
+	if err != nil {
+		t.Fatalf("invalid deltas: %v", err)
+	}
+
+	_, err = delta.Patch(source[1:], d)
+	if !errors.Is(err, delta.ErrSourceMismatch) {
+		t.Errorf("invalid deltas: expected delta.ErrSourceMismatch but got %v", err)
+	}
+
+	_, err = delta.Patch(source, d[:len(d)/2])
+	if !errors.Is(err, delta.ErrInvalidDelta) {
+		t.Errorf("invalid deltas: truncated: expected delta.ErrInvalidDelta but got %v", err)
+	}
+
+	for _, targetSize := range []uint64{delta.MaxTargetSize + 1, 1 << 62, ^uint64(0)} {
+		_, err = delta.Patch(source, deltaWithHeader(uint64(len(source)), targetSize))
+		if !errors.Is(err, delta.ErrInvalidDelta) {
+			t.Errorf("invalid deltas: target size %d: expected delta.ErrInvalidDelta but got %v", targetSize, err)
+		}
+	}
+
+	_, err = delta.Patch(source, deltaWithHeader(uint64(len(source)), delta.MaxTargetSize))
+	if !errors.Is(err, delta.ErrInvalidDelta) {
+		t.Errorf("invalid deltas: missing instructions: expected delta.ErrInvalidDelta but got %v", err)
+	}
+
+	_, err = delta.Patch(source, []byte("not a delta"))
+	if !errors.Is(err, delta.ErrInvalidDelta) {
+		t.Errorf("invalid deltas: magic: expected delta.ErrInvalidDelta but got %v", err)
+	}
+}
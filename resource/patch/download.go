@@ -0,0 +1,301 @@
+package patch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	partialSuffix   = ".part"
+	validatorSuffix = ".part.validator"
+)
+
+// A single entry within the download directive.
+//
+// Entries may be written in either the short form, where the value is only the
+// name of the downloaded resource:
+//
+//	"/v1.0.0/boot.cfg": "boot.cfg"
+//
+// or in the extended form, which allows the resource to be verified after it has
+// been downloaded:
+//
+//	"/v1.0.0/boot.cfg": { "name": "boot.cfg", "sha256": "...", "size": 1024 }
+type DownloadEntry struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+func (entry *DownloadEntry) UnmarshalJSON(data []byte) error {
+	name := ""
+	if err := json.Unmarshal(data, &name); err == nil {
+		*entry = DownloadEntry{Name: name}
+		return nil
+	}
+
+	type extended DownloadEntry
+	e := extended{}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return fmt.Errorf("download entry must be a string or an object: %w", err)
+	}
+
+	*entry = DownloadEntry(e)
+	return nil
+}
+
+func (entry DownloadEntry) MarshalJSON() ([]byte, error) {
+	if !entry.HasChecksum() && entry.Size == 0 {
+		return json.Marshal(entry.Name)
+	}
+
+	type extended DownloadEntry
+	return json.Marshal(extended(entry))
+}
+
+// Returns true if the entry specifies a SHA-256 hash.
+func (entry DownloadEntry) HasChecksum() bool {
+	return len(entry.SHA256) > 0
+}
+
+// Patches which implement Checksummed report which of their downloads cannot be verified once downloaded.
+type Checksummed interface {
+	// Returns the remote paths of the downloads which do not specify a sha256 hash, including the
+	// downloads within conditional blocks.
+	UnhashedDownloads() []string
+}
+
+// Returns an error wrapping ErrChecksumMissing if any of the patch's downloads do not specify a sha256
+// hash. Patches which do not implement Checksummed cannot be verified, so they are always refused.
+func RequireChecksums(p Patch) error {
+	checksummed, ok := p.(Checksummed)
+	if !ok {
+		return &PatchError{fmt.Errorf("%w: %s: downloads cannot be verified", ErrChecksumMissing, p.Version())}
+	}
+
+	if unhashed := checksummed.UnhashedDownloads(); len(unhashed) > 0 {
+		return &PatchError{fmt.Errorf("%w: %s: %s", ErrChecksumMissing, p.Version(), strings.Join(unhashed, ", "))}
+	}
+
+	return nil
+}
+
+// Checks the size and hash of a downloaded resource against the values specified by the entry.
+// Values which are not specified by the entry are not checked.
+func (entry DownloadEntry) Verify(size int64, sum hash.Hash) error {
+	if entry.Size > 0 && entry.Size != size {
+		return fmt.Errorf("%w: expected %d byte(s) but got %d", ErrChecksumMismatch, entry.Size, size)
+	}
+
+	if !entry.HasChecksum() {
+		return nil
+	}
+
+	actual := hex.EncodeToString(sum.Sum(nil))
+	if !strings.EqualFold(actual, entry.SHA256) {
+		return fmt.Errorf("%w: expected sha256 %s but got %s", ErrChecksumMismatch, strings.ToLower(entry.SHA256), actual)
+	}
+
+	return nil
+}
+
+func newChecksum() hash.Hash {
+	return sha256.New()
+}
+
+func checksumFile(name string) (int64, hash.Hash, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	checksum := newChecksum()
+	size, err := io.Copy(checksum, file)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return size, checksum, nil
+}
+
+func verifyFile(name string, entry DownloadEntry) error {
+	size, checksum, err := checksumFile(name)
+	if err != nil {
+		return err
+	}
+
+	return entry.Verify(size, checksum)
+}
+
+// Returns the value which should be sent within the If-Range header when resuming a
+// download of the response's resource. Weak ETags cannot be used with If-Range, so the
+// Last-Modified header is used as a fallback.
+func rangeValidator(header http.Header) string {
+	etag := header.Get("ETag")
+	if len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return header.Get("Last-Modified")
+}
+
+// Returns the first byte position of a Content-Range header in the form: "bytes {first}-{last}/{length}"
+func contentRangeStart(header http.Header) (int64, bool) {
+	var start, end int64
+	_, err := fmt.Sscanf(header.Get("Content-Range"), "bytes %d-%d", &start, &end)
+	return start, err == nil
+}
+
+// Adds the Range and If-Range headers to header if the partial download, partName, can be resumed.
+//
+// Returns the number of bytes which have already been downloaded, or 0 if the download
+// must start from the beginning.
+func resumeOffset(partName, validatorName string, header http.Header) int64 {
+	stat, err := os.Stat(partName)
+	if err != nil || stat.Size() == 0 {
+		return 0
+	}
+
+	validator, err := os.ReadFile(validatorName)
+	if err != nil || len(validator) == 0 {
+		return 0
+	}
+
+	header.Set("Range", fmt.Sprintf("bytes=%d-", stat.Size()))
+	header.Set("If-Range", string(validator))
+
+	return stat.Size()
+}
+
+func removePartial(partName, validatorName string) {
+	os.Remove(partName)
+	os.Remove(validatorName)
+}
+
+// Downloads the resource located by job.Path and saves it to job.Filename, returning the size
+// of the saved resource.
+//
+// The resource is first written to "{filename}.part". If the server responds with an ETag
+// or Last-Modified header, the header is saved alongside the partial download so that, if the
+// download is interrupted, the next call may resume the download with the Range and If-Range
+// headers. Servers which do not support ranges, or whose resource has since changed, respond
+// with the full resource, in which case the download starts over.
+//
+// Once the download is complete, the resource is verified against job.Entry and renamed to job.Filename.
+//
+// If ctx is cancelled, the partial download is removed rather than being kept for resuming.
+func fetchResource(ctx context.Context, server Server, job DownloadJob, observer Observer) (int64, error) {
+	size, err := doFetchResource(ctx, server, job, observer)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		removePartial(job.Filename+partialSuffix, job.Filename+validatorSuffix)
+		return 0, ctx.Err()
+	}
+
+	return size, err
+}
+
+func doFetchResource(ctx context.Context, server Server, job DownloadJob, observer Observer) (int64, error) {
+	partName := job.Filename + partialSuffix
+	validatorName := job.Filename + validatorSuffix
+
+	header := http.Header{}
+	offset := resumeOffset(partName, validatorName, header)
+
+	response, err := server.RemoteGetWithHeader(ctx, header, job.Path)
+	if err != nil {
+		return 0, fmt.Errorf("could not get url: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusUnauthorized {
+		return 0, ErrPatchesUnauthorized
+	}
+
+	if offset > 0 && response.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		log.Printf("Cannot resume \"%s\"; Restarting download", job.Path)
+		removePartial(partName, validatorName)
+		return doFetchResource(ctx, server, job, observer)
+	}
+
+	if response.StatusCode >= 400 {
+		return 0, fmt.Errorf("invalid response status code from server: %d", response.StatusCode)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if response.StatusCode == http.StatusPartialContent {
+		if start, ok := contentRangeStart(response.Header); !ok || start != offset {
+			log.Printf("Unexpected content range for \"%s\"; Restarting download", job.Path)
+			removePartial(partName, validatorName)
+			return doFetchResource(ctx, server, job, observer)
+		}
+
+		log.Printf("Resuming download \"%s\" at %d byte(s)", job.Path, offset)
+		flags = os.O_WRONLY | os.O_APPEND
+	} else {
+		offset = 0
+	}
+
+	if validator := rangeValidator(response.Header); len(validator) > 0 {
+		os.WriteFile(validatorName, []byte(validator), 0755)
+	} else {
+		os.Remove(validatorName)
+	}
+
+	size := job.Entry.Size
+	if size <= 0 && response.ContentLength >= 0 {
+		size = offset + response.ContentLength
+	}
+
+	notify(observer, Event{
+		Kind:    FileStarted,
+		Version: job.Version,
+		Path:    job.Path,
+		Bytes:   offset,
+		Size:    max(size, 0),
+	})
+
+	file, err := os.OpenFile(partName, flags, 0755)
+	if err != nil {
+		return 0, fmt.Errorf("could not open file in download directory: %w", err)
+	}
+
+	_, err = io.Copy(io.MultiWriter(file, progressWriter{observer, job}), response.Body)
+	file.Close()
+	if err != nil {
+		return 0, fmt.Errorf("download interrupted: %w", err)
+	}
+
+	// The body may have been read completely before the cancellation was noticed
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	written, checksum, err := checksumFile(partName)
+	if err != nil {
+		return 0, fmt.Errorf("could not read download: %w", err)
+	}
+
+	if err := job.Entry.Verify(written, checksum); err != nil {
+		removePartial(partName, validatorName)
+		return 0, err
+	}
+
+	err = os.Rename(partName, job.Filename)
+	if err != nil {
+		return 0, fmt.Errorf("could not save download: %w", errors.Join(err, os.Remove(partName)))
+	}
+	os.Remove(validatorName)
+
+	return written, nil
+}
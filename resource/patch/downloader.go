@@ -0,0 +1,334 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const DefaultConcurrentDownloads = 4
+
+// A single resource to be downloaded into a Local Patch Directory.
+type DownloadJob struct {
+	// The version of the patch which requested the download.
+	Version string
+
+	// The remote path of the resource, relative to the Remote Patch Directory.
+	Path string
+
+	// The local path which the resource is saved to.
+	Filename string
+
+	Entry DownloadEntry
+}
+
+// Patches which implement Downloadable can have their downloads scheduled alongside
+// the downloads of other patches.
+type Downloadable interface {
+	// Returns the jobs required to download the patch's resources. This method
+	// should return an error if the patch should not be downloaded.
+	DownloadJobs(Server, *RejectionList) ([]DownloadJob, error)
+}
+
+// Patches which implement Extractable unpack their downloaded archives once every download
+// scheduled alongside them has completed.
+type Extractable interface {
+	ExtractResources(context.Context, Server, Observer) error
+}
+
+type DownloadResult struct {
+	// The number of resources fetched from the server.
+	Downloaded int
+
+	// The number of resources copied from an identical download instead of being fetched.
+	Deduplicated int
+
+	Errors []error
+}
+
+// Returns all of the result's errors joined by errors.Join.
+func (result DownloadResult) Err() error {
+	return errors.Join(result.Errors...)
+}
+
+// Downloads patch resources using a bounded number of concurrent requests.
+type Downloader struct {
+	Concurrency int
+}
+
+func NewDownloader(concurrency int) *Downloader {
+	return &Downloader{Concurrency: concurrency}
+}
+
+func (downloader *Downloader) concurrency() int {
+	if downloader == nil || downloader.Concurrency <= 0 {
+		return DefaultConcurrentDownloads
+	}
+
+	return downloader.Concurrency
+}
+
+func copyDownload(source, destination string) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer destinationFile.Close()
+
+	_, err = io.Copy(destinationFile, sourceFile)
+	return err
+}
+
+// Downloads the first job in the group, and then copies the download to the rest of the group's jobs.
+func (downloader *Downloader) downloadGroup(ctx context.Context, server Server, group []DownloadJob, observer Observer, result *DownloadResult, mux *sync.Mutex) {
+	fail := func(job DownloadJob, err error) {
+		mux.Lock()
+		defer mux.Unlock()
+
+		if errors.Is(err, ErrPatchesUnauthorized) {
+			result.Errors = append(result.Errors, &PatchError{ErrPatchesUnauthorized})
+		} else if ctx.Err() != nil {
+			result.Errors = append(result.Errors, ctx.Err())
+		} else {
+			result.Errors = append(result.Errors, &PatchError{fmt.Errorf("%s: could not download \"%s\": %w", job.Version, job.Path, err)})
+		}
+	}
+
+	first := group[0]
+	if ctx.Err() != nil {
+		fail(first, ctx.Err())
+		return
+	}
+
+	log.Printf("Downloading \"%s\" (%s)", first.Path, first.Version)
+
+	size, err := fetchResource(ctx, server, first, observer)
+	notify(observer, Event{
+		Kind:    FileFinished,
+		Version: first.Version,
+		Path:    first.Path,
+		Bytes:   size,
+		Err:     err,
+	})
+
+	if err != nil {
+		for _, job := range group {
+			fail(job, err)
+		}
+		return
+	}
+
+	mux.Lock()
+	result.Downloaded++
+	mux.Unlock()
+
+	for _, job := range group[1:] {
+		if job.Filename == first.Filename {
+			continue
+		}
+
+		err := copyDownload(first.Filename, job.Filename)
+		if err == nil {
+			err = verifyFile(job.Filename, job.Entry)
+		}
+
+		if err != nil {
+			fail(job, err)
+			continue
+		}
+
+		mux.Lock()
+		result.Deduplicated++
+		mux.Unlock()
+	}
+}
+
+// Downloads all of the jobs, fetching at most downloader.Concurrency resources at once.
+//
+// Jobs which share the same remote path are only fetched once; the fetched resource is copied for
+// the remaining jobs. Jobs which save different remote paths to the same local file are not fetched,
+// and are reported with ErrDuplicateDownload. A failed job does not stop the other jobs from
+// completing, and every error is reported within the returned result.
+//
+// Events are only emitted for resources which are fetched, so DownloadStarted reports the number of
+// unique remote paths.
+//
+// Once ctx is done, no further resources are fetched, and the result contains the context's error.
+func (downloader *Downloader) Download(ctx context.Context, server Server, jobs []DownloadJob, observer Observer) DownloadResult {
+	result := DownloadResult{}
+
+	// Maps each local file to the remote path which is saved to it. Local files which are claimed
+	// by more than one remote path would otherwise be written by multiple workers at once.
+	remotePaths := make(map[string]string)
+	duplicates := make(map[string]bool)
+
+	for _, job := range jobs {
+		filename := filepath.Clean(job.Filename)
+
+		path, ok := remotePaths[filename]
+		if !ok {
+			remotePaths[filename] = job.Path
+			continue
+		}
+
+		if path != job.Path && !duplicates[filename] {
+			duplicates[filename] = true
+			result.Errors = append(result.Errors, &PatchError{fmt.Errorf("%s: cannot download \"%s\": %w: \"%s\" is also downloaded from \"%s\"", job.Version, job.Path, ErrDuplicateDownload, job.Filename, path)})
+		}
+	}
+
+	groups := make(map[string][]DownloadJob)
+	paths := []string{}
+
+	totalSize := int64(0)
+	knownSize := true
+
+	for _, job := range jobs {
+		if duplicates[filepath.Clean(job.Filename)] {
+			continue
+		}
+
+		if _, ok := groups[job.Path]; !ok {
+			paths = append(paths, job.Path)
+
+			totalSize += job.Entry.Size
+			knownSize = knownSize && job.Entry.Size > 0
+		}
+
+		groups[job.Path] = append(groups[job.Path], job)
+	}
+
+	if !knownSize {
+		totalSize = 0
+	}
+
+	notify(observer, Event{
+		Kind:  DownloadStarted,
+		Count: len(paths),
+		Size:  totalSize,
+	})
+
+	mux := sync.Mutex{}
+
+	queue := make(chan []DownloadJob)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < min(downloader.concurrency(), len(paths)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range queue {
+				downloader.downloadGroup(ctx, server, group, observer, &result, &mux)
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		queue <- groups[path]
+	}
+	close(queue)
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		// Report the cancellation once, rather than for every remaining job
+		errs := []error{ctx.Err()}
+		for _, err := range result.Errors {
+			if !errors.Is(err, ctx.Err()) {
+				errs = append(errs, err)
+			}
+		}
+		result.Errors = errs
+	}
+
+	log.Printf("Downloaded %d resource(s); Deduplicated %d resource(s); %d error(s)", result.Downloaded, result.Deduplicated, len(result.Errors))
+	return result
+}
+
+// Returns the size of each of the remote resources, mapped by their paths, from the Content-Length of a HEAD
+// request, requesting at most downloader.Concurrency resources at once. Sizes which are unknown are -1.
+func (downloader *Downloader) Sizes(ctx context.Context, server Server, paths []string) map[string]int64 {
+	sizes := make(map[string]int64, len(paths))
+	mux := sync.Mutex{}
+
+	queue := make(chan string)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < min(downloader.concurrency(), len(paths)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range queue {
+				size := remoteSize(ctx, server, path)
+
+				mux.Lock()
+				sizes[path] = size
+				mux.Unlock()
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		queue <- path
+	}
+	close(queue)
+
+	wg.Wait()
+
+	return sizes
+}
+
+// Returns the name which the resource located by path is saved as, relative to the Local Patch Directory.
+// If the entry does not specify a name, the base of path is used.
+func downloadName(path string, entry DownloadEntry) (string, error) {
+	name := entry.Name
+	if len(path) > 0 && len(name) == 0 {
+		name = filepath.Base(path)
+	}
+
+	if len(name) == 0 {
+		return "", &PatchError{errors.New("invalid download name: name is empty")}
+	}
+
+	if !filepath.IsLocal(name) {
+		return "", &PatchError{fmt.Errorf("invalid download name \"%s\": name is nonlocal", name)}
+	}
+
+	return name, nil
+}
+
+// Creates a job for each of the entries within a download directive, where
+// downloadPath is the Local Patch Directory.
+func newDownloadJobs(version, downloadPath string, downloads map[string]DownloadEntry) ([]DownloadJob, error) {
+	jobs := []DownloadJob{}
+
+	for path, entry := range downloads {
+		name, err := downloadName(path, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		filename := filepath.Join(downloadPath, name)
+		os.MkdirAll(filepath.Dir(filename), 0755)
+
+		jobs = append(jobs, DownloadJob{
+			Version:  version,
+			Path:     path,
+			Filename: filename,
+			Entry:    entry,
+		})
+	}
+
+	return jobs, nil
+}
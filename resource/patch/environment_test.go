@@ -2,23 +2,58 @@ package patch_test
 
 import (
 	"context"
+	"io/fs"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/I-Am-Dench/nimbus-launcher/ldf"
 	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch/tppserver"
 	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
 )
 
+// The modification time of every file served by the test patch server.
+var patchServerModTime = time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC)
+
 type environment struct {
 	Dir string
 
 	PatchServer  *http.Server
 	ServerConfig *server.Server
 	Rejections   *patch.RejectionList
+	Downloader   *patch.Downloader
+	Observer     patch.Observer
+
+	requests *requestCounter
+}
+
+// Returns the number of requests the patch server has received for path.
+func (env *environment) Requests(path string) int {
+	return env.requests.Get(path)
+}
+
+type requestCounter struct {
+	m   map[string]int
+	mux sync.Mutex
+}
+
+func (counter *requestCounter) Add(path string) {
+	counter.mux.Lock()
+	defer counter.mux.Unlock()
+	counter.m[path]++
+}
+
+func (counter *requestCounter) Get(path string) int {
+	counter.mux.Lock()
+	defer counter.mux.Unlock()
+	return counter.m[path]
 }
 
 func (env *environment) ClientDir() string {
@@ -51,30 +86,40 @@ func (fs fileSystem) Init(dir string, t *testing.T) {
 	}
 }
 
-func newPatchServer(t *testing.T, ctx context.Context, fs fileSystem) *http.Server {
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		t.Logf("[PATCH SERVER] {%s} %s", r.Method, r.URL.Path)
+// Serves the files within dir as an fs.FS. The files are looked up each time they are opened,
+// so changes made after the patch server has started are served.
+type directoryFS struct {
+	files fileSystem
+	dir   string
+}
 
-		if r.Method != http.MethodGet {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
+func (directory directoryFS) Open(name string) (fs.File, error) {
+	mapFS := fstest.MapFS{}
+	for path, data := range directory.files {
+		if relativePath, ok := strings.CutPrefix(path, directory.dir+"/"); ok {
+			mapFS[relativePath] = &fstest.MapFile{Data: data, Mode: 0755, ModTime: patchServerModTime}
 		}
+	}
 
-		data, ok := fs[r.URL.Path]
-		if !ok {
-			w.WriteHeader(http.StatusNotFound)
-			return
-		}
+	return mapFS.Open(name)
+}
 
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
+func newPatchServer(t *testing.T, ctx context.Context, fs fileSystem, requests *requestCounter) *http.Server {
+	patchServer := tppserver.New(tppserver.Config{
+		FS:     directoryFS{files: fs, dir: "/patches"},
+		Prefix: "/patches",
+	})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("[PATCH SERVER] {%s} %s", r.Method, r.URL.Path)
+		requests.Add(r.URL.Path)
+
+		patchServer.ServeHTTP(w, r)
 	})
 
 	return &http.Server{
 		Addr:    "127.0.0.1:3000",
-		Handler: mux,
+		Handler: handler,
 		BaseContext: func(l net.Listener) context.Context {
 			return ctx
 		},
@@ -106,11 +151,17 @@ func setup(t *testing.T, serverFS fileSystem) (*environment, func()) {
 	t.Logf("Using temp dir \"%s\"", dir)
 
 	env := &environment{
-		Dir: dir,
+		Dir:      dir,
+		requests: &requestCounter{m: make(map[string]int)},
 	}
 
+	// Retries are kept short, so that requests which are expected to fail do not slow the tests
+	config := server.DefaultTransportConfig()
+	config.RetryDelay = time.Millisecond
+	server.SetTransportConfig(config)
+
 	ctx := context.Background()
-	env.PatchServer = newPatchServer(t, ctx, serverFS)
+	env.PatchServer = newPatchServer(t, ctx, serverFS, env.requests)
 	env.PatchServer.RegisterOnShutdown(func() {
 		t.Logf("Patch server shutdown.")
 	})
@@ -122,6 +173,7 @@ func setup(t *testing.T, serverFS fileSystem) (*environment, func()) {
 	}
 
 	env.Rejections = patch.NewRejectionList(filepath.Join(dir, "rejections.json"))
+	env.Downloader = patch.NewDownloader(2)
 
 	return env, func() {
 		env.PatchServer.Shutdown(ctx)
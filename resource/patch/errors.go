@@ -9,6 +9,13 @@ var (
 	ErrPatchesUnsupported  = errors.New("patches unsupported on remote")
 	ErrPatchesUnavailable  = errors.New("patch server could not be reached")
 	ErrPatchesUnauthorized = errors.New("invalid patch token")
+	ErrChecksumMismatch    = errors.New("checksum mismatch")
+	ErrSignatureMissing    = errors.New("missing signature")
+	ErrSignatureInvalid    = errors.New("invalid signature")
+	ErrUnsupportedProtocol = errors.New("unsupported protocol")
+	ErrConflict            = errors.New("conflicting changes")
+	ErrDuplicateDownload   = errors.New("duplicate download name")
+	ErrChecksumMissing     = errors.New("missing checksum")
 )
 
 type PatchError struct {
@@ -0,0 +1,144 @@
+package patch
+
+import (
+	"log"
+)
+
+type EventKind int
+
+const (
+	// Emitted once before a set of resources start downloading.
+	DownloadStarted = EventKind(iota)
+
+	// Emitted when a resource starts downloading.
+	FileStarted
+
+	// Emitted each time bytes of a resource are written to the Local Patch Directory.
+	BytesWritten
+
+	// Emitted when a resource has finished downloading, successfully or not.
+	FileFinished
+
+	// Emitted after a patch directive has been applied.
+	DirectiveApplied
+
+	// Emitted when a patch starts processing one of its dependencies.
+	DependencyEntered
+)
+
+func (kind EventKind) String() string {
+	switch kind {
+	case DownloadStarted:
+		return "DownloadStarted"
+	case FileStarted:
+		return "FileStarted"
+	case BytesWritten:
+		return "BytesWritten"
+	case FileFinished:
+		return "FileFinished"
+	case DirectiveApplied:
+		return "DirectiveApplied"
+	case DependencyEntered:
+		return "DependencyEntered"
+	default:
+		return "Unknown"
+	}
+}
+
+type Event struct {
+	Kind EventKind
+
+	// The version of the patch which emitted the event. For DependencyEntered, this is the
+	// version of the dependency.
+	Version string
+
+	// The remote path of the resource for FileStarted, BytesWritten, and FileFinished.
+	//
+	// The client resource, or the directive's value, for DirectiveApplied.
+	Path string
+
+	// The name of the applied directive (i.e. "extract", "replace", "delta", "add", "remove", or "boot") for DirectiveApplied.
+	Directive string
+
+	// The number of resources which will be downloaded for DownloadStarted.
+	Count int
+
+	// The number of bytes which were already downloaded when resuming for FileStarted.
+	//
+	// The number of bytes written for BytesWritten.
+	//
+	// The total size of the downloaded resource for FileFinished.
+	Bytes int64
+
+	// The total number of bytes which will be downloaded for DownloadStarted, or the expected
+	// size of the resource for FileStarted. Size is 0 when it cannot be known ahead of time.
+	Size int64
+
+	// The error which stopped the download for FileFinished, if any.
+	Err error
+}
+
+// Receives events emitted while a patch is downloaded, updated, and transferred.
+//
+// Resources may be downloaded concurrently, so implementations of Notify must be safe
+// to call from multiple goroutines.
+type Observer interface {
+	Notify(Event)
+}
+
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) Notify(event Event) {
+	f(event)
+}
+
+func notify(observer Observer, event Event) {
+	if observer != nil {
+		observer.Notify(event)
+	}
+}
+
+type logObserver struct {
+	logger *log.Logger
+}
+
+// Returns an Observer which writes every event, except BytesWritten, to logger. If logger is nil,
+// events are written to the standard logger.
+func NewLogObserver(logger *log.Logger) Observer {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &logObserver{logger}
+}
+
+func (observer *logObserver) Notify(event Event) {
+	switch event.Kind {
+	case DownloadStarted:
+		observer.logger.Printf("[%s] count=%d size=%d", event.Kind, event.Count, event.Size)
+	case FileStarted:
+		observer.logger.Printf("[%s] version=%s path=%s size=%d resumed=%d", event.Kind, event.Version, event.Path, event.Size, event.Bytes)
+	case FileFinished:
+		observer.logger.Printf("[%s] version=%s path=%s bytes=%d err=%v", event.Kind, event.Version, event.Path, event.Bytes, event.Err)
+	case DirectiveApplied:
+		observer.logger.Printf("[%s] version=%s directive=%s path=%s", event.Kind, event.Version, event.Directive, event.Path)
+	case DependencyEntered:
+		observer.logger.Printf("[%s] version=%s", event.Kind, event.Version)
+	}
+}
+
+// An io.Writer which emits BytesWritten for every write.
+type progressWriter struct {
+	observer Observer
+	job      DownloadJob
+}
+
+func (writer progressWriter) Write(p []byte) (int, error) {
+	notify(writer.observer, Event{
+		Kind:    BytesWritten,
+		Version: writer.job.Version,
+		Path:    writer.job.Path,
+		Bytes:   int64(len(p)),
+	})
+	return len(p), nil
+}
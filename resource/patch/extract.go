@@ -0,0 +1,142 @@
+package patch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returns the path of the archive entry, name, within the directory. Returns an error if
+// the entry's path is nonlocal.
+func archiveEntryPath(directory, name string) (string, error) {
+	cleaned := filepath.FromSlash(name)
+	if !filepath.IsLocal(cleaned) {
+		return "", fmt.Errorf("invalid archive entry \"%s\": path is nonlocal", name)
+	}
+
+	return filepath.Join(directory, cleaned), nil
+}
+
+func extractFile(path string, mode os.FileMode, r io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, r)
+	return err
+}
+
+func extractZip(name, directory string) error {
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		path, err := archiveEntryPath(directory, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := entry.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !mode.IsRegular() {
+			log.Printf("Skipping archive entry \"%s\": not a regular file", entry.Name)
+			continue
+		}
+
+		r, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("could not open archive entry \"%s\": %w", entry.Name, err)
+		}
+
+		err = extractFile(path, mode.Perm(), r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("could not extract \"%s\": %w", entry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func extractTarGz(name, directory string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decompressor, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer decompressor.Close()
+
+	archive := tar.NewReader(decompressor)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		path, err := archiveEntryPath(directory, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			err := extractFile(path, header.FileInfo().Mode().Perm(), archive)
+			if err != nil {
+				return fmt.Errorf("could not extract \"%s\": %w", header.Name, err)
+			}
+		default:
+			log.Printf("Skipping archive entry \"%s\": not a regular file", header.Name)
+		}
+	}
+}
+
+// Unpacks the .zip, .tar.gz, or .tgz archive, name, into the directory. Every entry within the archive
+// must be local to the directory.
+func extractArchive(name, directory string) error {
+	lower := strings.ToLower(name)
+
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return extractZip(name, directory)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return extractTarGz(name, directory)
+	default:
+		return fmt.Errorf("unsupported archive format \"%s\"", filepath.Base(name))
+	}
+}
@@ -0,0 +1,385 @@
+package patch
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// A single problem within a patch.json or summary.json.
+type Problem struct {
+	// The file containing the problem, relative to the Remote Patch Directory, e.g. "v1.0.0/patch.json".
+	File string
+
+	// The directive, and the entry within the directive, where the problem was found, e.g. `replace["a"]`.
+	// Location is empty for problems with the patch as a whole.
+	Location string
+
+	Message string
+}
+
+func (problem Problem) String() string {
+	if len(problem.Location) == 0 {
+		return fmt.Sprintf("%s: %s", problem.File, problem.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", problem.File, problem.Location, problem.Message)
+}
+
+// Returns an error which lists each of the problems, or nil if there are no problems.
+func ProblemsError(problems []Problem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	lines := []string{}
+	for _, problem := range problems {
+		lines = append(lines, problem.String())
+	}
+
+	return &PatchError{fmt.Errorf("found %d problem(s):\n%s", len(problems), strings.Join(lines, "\n"))}
+}
+
+// Reads a resource from the Remote Patch Directory, where path is relative to the directory, e.g. "/v1.0.0/boot.cfg".
+type ResourceReader func(path string) ([]byte, error)
+
+// Returns a ResourceReader which requests resources from the server.
+func RemoteReader(ctx context.Context, server Server) ResourceReader {
+	return func(path string) ([]byte, error) {
+		response, err := server.RemoteGet(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode >= 400 {
+			return nil, fmt.Errorf("invalid response status code from server: %d", response.StatusCode)
+		}
+
+		return io.ReadAll(response.Body)
+	}
+}
+
+type LintOptions struct {
+	// Reads the patch's resources. If Read is nil, the contents of resources are not checked.
+	Read ResourceReader
+
+	// If true, every download is read and checked against its size and hash. Otherwise, only the
+	// resources which are needed to check the patch.json, e.g. the boot.cfg, are read.
+	VerifyDownloads bool
+}
+
+// Patches which implement Linter can check their directives for problems before they are applied.
+type Linter interface {
+	Lint(options LintOptions) []Problem
+}
+
+// Returns every problem found within the patch. Patches which do not implement Linter are not checked,
+// other than their version name.
+func Lint(p Patch, options LintOptions) []Problem {
+	if linter, ok := p.(Linter); ok {
+		return linter.Lint(options)
+	}
+
+	problems := []Problem{}
+	if err := ValidateVersionName(p.Version()); err != nil {
+		problems = append(problems, Problem{File: p.Version() + "/patch.json", Message: err.Error()})
+	}
+	return problems
+}
+
+// Returns every problem found within the summary.json.
+func (summary Summary) Lint() []Problem {
+	problems := []Problem{}
+	add := func(location, format string, a ...any) {
+		problems = append(problems, Problem{File: "summary.json", Location: location, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if !Supports(summary.Runner) {
+		add("runner", "unsupported runner \"%s\"", summary.Runner)
+	}
+
+	available := make(map[string]bool)
+	for i, version := range summary.AvailableVersions {
+		location := fmt.Sprintf("availableVersions[%d]", i)
+
+		if err := ValidateVersionName(version); err != nil {
+			add(location, "%v", err)
+		}
+
+		if available[version] {
+			add(location, "\"%s\" is listed more than once", version)
+		}
+		available[version] = true
+	}
+
+	for _, version := range sortedKeys(summary.Versions) {
+		location := fmt.Sprintf("versions[%q]", version)
+
+		if !available[version] && version != summary.CurrentVersion {
+			add(location, "\"%s\" is not one of the available versions", version)
+		}
+
+		for _, problem := range summary.Versions[version].lint() {
+			add(location, "%s", problem)
+		}
+	}
+
+	if err := ValidateVersionName(summary.CurrentVersion); err != nil {
+		add("currentVersion", "%v", err)
+	} else if !available[summary.CurrentVersion] {
+		add("currentVersion", "\"%s\" is not one of the available versions", summary.CurrentVersion)
+	}
+
+	return problems
+}
+
+// Returns the problems within the metadata.
+func (metadata Metadata) lint() []string {
+	problems := []string{}
+
+	if _, ok := metadata.Released(); len(metadata.ReleaseDate) > 0 && !ok {
+		problems = append(problems, fmt.Sprintf("invalid releaseDate \"%s\": expected YYYY-MM-DD or RFC 3339", metadata.ReleaseDate))
+	}
+
+	if metadata.DownloadSize < 0 {
+		problems = append(problems, "invalid downloadSize: size cannot be negative")
+	}
+
+	return problems
+}
+
+func validSHA256(sum string) bool {
+	data, err := hex.DecodeString(sum)
+	return err == nil && len(data) == 32
+}
+
+// Returns true if the resource, relative to the Local Patch Directory, is either downloaded or may be
+// unpacked from one of the extracted archives.
+func (patch *Tpp) providesResource(names map[string]bool, resource string) bool {
+	resource = filepath.Clean(resource)
+	if names[resource] {
+		return true
+	}
+
+	for _, directory := range patch.Extract {
+		if len(directory) == 0 {
+			directory = "."
+		}
+
+		relativePath, err := filepath.Rel(filepath.Clean(directory), resource)
+		if err == nil && filepath.IsLocal(relativePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (patch *Tpp) Lint(options LintOptions) []Problem {
+	base := *patch
+	base.When = nil
+
+	verified := make(map[string]bool)
+	problems := base.lint(options, verified)
+
+	reported := make(map[string]bool)
+	for _, problem := range problems {
+		reported[problem.String()] = true
+	}
+
+	// Each conditional block is checked as part of the patch it is merged into, and only the problems
+	// which are caused by the block are reported
+	for i, conditional := range patch.When {
+		location := fmt.Sprintf("when[%d]", i)
+
+		if len(conditional.OS) == 0 && len(conditional.Locale) == 0 {
+			problems = append(problems, Problem{File: patch.version + "/patch.json", Location: location, Message: "block has no conditions"})
+		}
+
+		for _, value := range append(append([]string{}, conditional.OS...), conditional.Locale...) {
+			if len(strings.TrimSpace(value)) == 0 {
+				problems = append(problems, Problem{File: patch.version + "/patch.json", Location: location, Message: "condition is empty"})
+			}
+		}
+
+		effective := base
+		effective.Directives = base.Directives.merge(conditional.Directives)
+
+		for _, problem := range effective.lint(options, verified) {
+			if reported[problem.String()] {
+				continue
+			}
+
+			problem.Location = location + "." + problem.Location
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems
+}
+
+// Returns the problems within the patch's unconditional directives. Downloads whose remote paths are
+// within verified are not verified again.
+func (patch *Tpp) lint(options LintOptions, verified map[string]bool) []Problem {
+	problems := []Problem{}
+	add := func(location, format string, a ...any) {
+		problems = append(problems, Problem{File: patch.version + "/patch.json", Location: location, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if err := ValidateVersionName(patch.version); err != nil {
+		add("", "%v", err)
+	}
+
+	for i, entry := range patch.Dependencies {
+		location := fmt.Sprintf("depend[%d]", i)
+		dependency := ParseDependency(entry)
+
+		if err := ValidateVersionName(dependency.Version); err != nil {
+			add(location, "%v", err)
+		} else if dependency.Version == patch.version {
+			add(location, "patch cannot depend on itself")
+		}
+	}
+
+	for _, problem := range patch.Describe().lint() {
+		add("metadata", "%s", problem)
+	}
+
+	for i, version := range patch.Precedence {
+		location := fmt.Sprintf("precedence[%d]", i)
+
+		if err := ValidateVersionName(version); err != nil {
+			add(location, "%v", err)
+		} else if version == patch.version {
+			add(location, "patch cannot take precedence over itself")
+		}
+	}
+
+	// The name of each download, and the remote path which it is downloaded from
+	names := make(map[string]bool)
+	remotePaths := make(map[string]string)
+
+	for _, path := range sortedKeys(patch.Download) {
+		entry := patch.Download[path]
+		location := fmt.Sprintf("download[%q]", path)
+
+		if len(strings.Trim(path, "/")) == 0 {
+			add(location, "remote path is empty")
+		}
+
+		name, err := downloadName(path, entry)
+		if err != nil {
+			add(location, "%v", err.(*PatchError).Unwrap())
+			continue
+		}
+		name = filepath.Clean(name)
+
+		if names[name] {
+			add(location, "\"%s\" is downloaded more than once", name)
+		}
+		names[name] = true
+		remotePaths[name] = path
+
+		if entry.HasChecksum() && !validSHA256(entry.SHA256) {
+			add(location, "invalid sha256: expected a hex encoded SHA-256 hash")
+		}
+
+		if entry.Size < 0 {
+			add(location, "invalid size: size cannot be negative")
+		}
+
+		if options.Read != nil && options.VerifyDownloads && !verified[path] {
+			verified[path] = true
+
+			data, err := options.Read(path)
+			if err != nil {
+				add(location, "could not read resource: %v", err)
+				continue
+			}
+
+			checksum := newChecksum()
+			checksum.Write(data)
+			if err := entry.Verify(int64(len(data)), checksum); err != nil {
+				add(location, "%v", err)
+			}
+		}
+	}
+
+	for _, archive := range sortedKeys(patch.Extract) {
+		location := fmt.Sprintf("extract[%q]", archive)
+
+		if !filepath.IsLocal(archive) {
+			add(location, "archive path is nonlocal")
+		} else if !names[filepath.Clean(archive)] {
+			add(location, "archive \"%s\" is not downloaded", archive)
+		}
+
+		if directory := patch.Extract[archive]; len(directory) > 0 && !filepath.IsLocal(directory) {
+			add(location, "extract directory \"%s\" is nonlocal", directory)
+		}
+	}
+
+	if boot := patch.Update.Boot; len(boot) > 0 {
+		if !filepath.IsLocal(boot) {
+			add("update.boot", "path is nonlocal")
+		} else if !patch.providesResource(names, boot) {
+			add("update.boot", "\"%s\" is not downloaded", boot)
+		} else if path, ok := remotePaths[filepath.Clean(boot)]; ok && options.Read != nil {
+			if _, err := readBootConfig(options.Read, path); err != nil {
+				add("update.boot", "%v", err)
+			}
+		}
+	}
+
+	// Maps each changed client resource to the location which first changed it
+	changed := make(map[string]string)
+	checkTransfer := func(location, source, destination string) {
+		if !filepath.IsLocal(source) {
+			add(location, "source path is nonlocal")
+		} else if !patch.providesResource(names, source) {
+			add(location, "source \"%s\" is not downloaded", source)
+		}
+
+		if !filepath.IsLocal(destination) {
+			add(location, "destination \"%s\" is nonlocal", destination)
+			return
+		}
+
+		destination = filepath.Clean(destination)
+		if previous, ok := changed[destination]; ok {
+			add(location, "\"%s\" is already changed by %s", filepath.ToSlash(destination), previous)
+		}
+		changed[destination] = location
+	}
+
+	for _, source := range sortedKeys(patch.Replace) {
+		checkTransfer(fmt.Sprintf("replace[%q]", source), source, patch.Replace[source])
+	}
+
+	for _, source := range sortedKeys(patch.Delta) {
+		location := fmt.Sprintf("delta[%q]", source)
+		entry := patch.Delta[source]
+
+		checkTransfer(location, source, entry.Path)
+
+		if !validSHA256(entry.SHA256) {
+			add(location, "invalid sha256: expected a hex encoded SHA-256 hash of the patched resource")
+		}
+	}
+
+	for _, source := range sortedKeys(patch.Add) {
+		checkTransfer(fmt.Sprintf("add[%q]", source), source, patch.Add[source])
+	}
+
+	for i, resource := range patch.Remove {
+		if !filepath.IsLocal(resource) {
+			add(fmt.Sprintf("remove[%d]", i), "path is nonlocal")
+		}
+	}
+
+	return problems
+}
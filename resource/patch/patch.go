@@ -1,23 +1,33 @@
 package patch
 
-import "github.com/I-Am-Dench/nimbus-launcher/client"
+import (
+	"context"
+
+	"github.com/I-Am-Dench/nimbus-launcher/client"
+)
 
 type Patch interface {
 	// Returns the version of this patch
 	Version() string
 
 	// Downloads resources needed by the patch to the path returned by Server.DownloadDir().
-	DownloadResources(Server, *RejectionList) error
+	//
+	// If the Downloader is nil, resources are downloaded with DefaultConcurrentDownloads.
+	//
+	// For each of the following methods, the Observer receives the events emitted by the patch,
+	// and may be nil. Once the context is done, the method stops as soon as possible and returns
+	// the context's error.
+	DownloadResources(context.Context, Server, *RejectionList, *Downloader, Observer) error
 
 	// Updates the Server's configuration.
-	UpdateResources(Server, *RejectionList) error
+	UpdateResources(context.Context, Server, *RejectionList, *Downloader, Observer) error
 
 	// Transfers resources downloaded by the patch into the clientDirectory.
-	TransferResources(clientDirectory string, resources client.Resources, server Server) error
+	TransferResources(ctx context.Context, clientDirectory string, resources client.Resources, server Server, observer Observer) error
 
 	// Transfers resources downloaded by the patch into the clientDirectory, transferring dependencies' resources
 	// if possible.
-	TransferResourcesWithDependencies(clientDirectory string, resources client.Resources, server Server) error
+	TransferResourcesWithDependencies(ctx context.Context, clientDirectory string, resources client.Resources, server Server, observer Observer) error
 
 	// A stringified summary of this patch.
 	Summary() string
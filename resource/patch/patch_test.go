@@ -1,17 +1,32 @@
 package patch_test
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/fs"
 	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/I-Am-Dench/nimbus-launcher/client"
 	"github.com/I-Am-Dench/nimbus-launcher/ldf"
 	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch/delta"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
 )
 
 type replacementCache struct {
@@ -76,9 +91,50 @@ func (cache *additionsCache) Has(path string) bool {
 	return ok
 }
 
+type journalCache struct {
+	m map[string]struct{}
+}
+
+func (journal *journalCache) Add(path string) error {
+	if journal.m == nil {
+		journal.m = make(map[string]struct{})
+	}
+
+	journal.m[path] = struct{}{}
+	return nil
+}
+
+func (journal *journalCache) Get(path string) (string, error) {
+	_, ok := journal.m[path]
+	if !ok {
+		return "", fmt.Errorf("journal: \"%s\" does not exist", path)
+	}
+
+	return path, nil
+}
+
+func (journal *journalCache) List() ([]string, error) {
+	paths := []string{}
+	for path := range journal.m {
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func (journal *journalCache) Has(path string) bool {
+	_, ok := journal.m[path]
+	return ok
+}
+
+func (journal *journalCache) Clear() error {
+	journal.m = nil
+	return nil
+}
+
 type resources struct {
 	replacements replacementCache
 	additions    additionsCache
+	journal      journalCache
 }
 
 func (resources *resources) Replacements() client.Cache[client.Resource] {
@@ -89,10 +145,52 @@ func (resources *resources) Additions() client.Cache[string] {
 	return &resources.additions
 }
 
+func (resources *resources) Journal() client.Journal {
+	return &resources.journal
+}
+
 func (*resources) Close() error {
 	return nil
 }
 
+type eventRecorder struct {
+	events []patch.Event
+	mux    sync.Mutex
+}
+
+func (recorder *eventRecorder) Notify(event patch.Event) {
+	recorder.mux.Lock()
+	defer recorder.mux.Unlock()
+	recorder.events = append(recorder.events, event)
+}
+
+func (recorder *eventRecorder) Count(kind patch.EventKind) int {
+	recorder.mux.Lock()
+	defer recorder.mux.Unlock()
+
+	count := 0
+	for _, event := range recorder.events {
+		if event.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the sum of bytes from each BytesWritten event.
+func (recorder *eventRecorder) Bytes() int64 {
+	recorder.mux.Lock()
+	defer recorder.mux.Unlock()
+
+	sum := int64(0)
+	for _, event := range recorder.events {
+		if event.Kind == patch.BytesWritten {
+			sum += event.Bytes
+		}
+	}
+	return sum
+}
+
 func readTestPatch(name string) []byte {
 	data, err := os.ReadFile(filepath.Join("test_patches", name))
 	if err != nil {
@@ -102,6 +200,54 @@ func readTestPatch(name string) []byte {
 	return data
 }
 
+func zipArchive(files map[string]string) []byte {
+	buf := bytes.Buffer{}
+	archive := zip.NewWriter(&buf)
+
+	for name, contents := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		w.Write([]byte(contents))
+	}
+
+	if err := archive.Close(); err != nil {
+		panic(err)
+	}
+
+	return buf.Bytes()
+}
+
+func tarGzArchive(files map[string]string) []byte {
+	buf := bytes.Buffer{}
+	compressor := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(compressor)
+
+	for name, contents := range files {
+		err := archive.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			panic(err)
+		}
+		archive.Write([]byte(contents))
+	}
+
+	if err := archive.Close(); err != nil {
+		panic(err)
+	}
+
+	if err := compressor.Close(); err != nil {
+		panic(err)
+	}
+
+	return buf.Bytes()
+}
+
 func serverFileSystem(updatedBoot *ldf.BootConfig) fileSystem {
 	fs := make(fileSystem)
 
@@ -111,6 +257,23 @@ func serverFileSystem(updatedBoot *ldf.BootConfig) fileSystem {
 	fs["/patches/v4.0.0/patch.json"] = readTestPatch("patch4.json")
 	fs["/patches/v5.0.0/patch.json"] = readTestPatch("patch5.json")
 	fs["/patches/v6.0.0/patch.json"] = readTestPatch("patch6.json")
+	fs["/patches/v7.0.0/patch.json"] = readTestPatch("patch7.json")
+	fs["/patches/v8.0.0/patch.json"] = readTestPatch("patch8.json")
+	fs["/patches/v9.0.0/patch.json"] = readTestPatch("patch9.json")
+	fs["/patches/v10.0.0/patch.json"] = readTestPatch("patch10.json")
+	fs["/patches/v11.0.0/patch.json"] = readTestPatch("patch11.json")
+	fs["/patches/v12.0.0/patch.json"] = readTestPatch("patch12.json")
+	fs["/patches/v13.0.0/patch.json"] = readTestPatch("patch13.json")
+	fs["/patches/v14.0.0/patch.json"] = readTestPatch("patch14.json")
+	fs["/patches/v15.0.0/patch.json"] = readTestPatch("patch15.json")
+	fs["/patches/v16.0.0/patch.json"] = readTestPatch("patch16.json")
+	fs["/patches/v17.0.0/patch.json"] = readTestPatch("patch17.json")
+	fs["/patches/v18.0.0/patch.json"] = readTestPatch("patch18.json")
+	fs["/patches/v19.0.0/patch.json"] = readTestPatch("patch19.json")
+	fs["/patches/v20.0.0/patch.json"] = readTestPatch("patch20.json")
+	fs["/patches/v21.0.0/patch.json"] = readTestPatch("patch21.json")
+	fs["/patches/v22.0.0/patch.json"] = readTestPatch("patch22.json")
+	fs["/patches/v23.0.0/patch.json"] = readTestPatch("patch23.json")
 
 	fs["/patches/invalid_version/patch.json"] = readTestPatch("patch1.json") // Could be any patch
 
@@ -123,6 +286,17 @@ func serverFileSystem(updatedBoot *ldf.BootConfig) fileSystem {
 	fs["/patches/common/b"] = []byte("Test 2")
 	fs["/patches/common/c"] = []byte("Test 3")
 
+	fileDelta, err := delta.Diff([]byte("default data 1"), []byte("default data 1 (patched)"))
+	if err != nil {
+		panic(err)
+	}
+
+	fs["/patches/common/file1.delta"] = fileDelta
+
+	fs["/patches/common/pack.zip"] = zipArchive(map[string]string{"a": "Test 1", "dir/c": "Test 3"})
+	fs["/patches/common/pack.tar.gz"] = tarGzArchive(map[string]string{"dir/b": "Test 2"})
+	fs["/patches/common/slip.zip"] = zipArchive(map[string]string{"../../../../slip": "Test 1"})
+
 	fs["/patches/boot.cfg"] = data
 
 	return fs
@@ -191,17 +365,17 @@ func testPatchVersion(t *testing.T, env *environment, resources client.Resources
 	t.Log("Initializing client contents:")
 	clientFS.Init(env.ClientDir(), t)
 
-	patch, err := env.ServerConfig.GetPatch(version)
+	patch, err := env.ServerConfig.GetPatch(context.Background(), version)
 	if err != nil {
 		t.Fatalf("test patching: %s: %v", version, err)
 	}
 
-	err = patch.UpdateResources(env.ServerConfig, env.Rejections)
+	err = patch.UpdateResources(context.Background(), env.ServerConfig, env.Rejections, env.Downloader, env.Observer)
 	if err != nil {
 		t.Fatalf("test patching: %s: update resources: %v", version, err)
 	}
 
-	err = patch.TransferResources(env.ClientDir(), resources, env.ServerConfig)
+	err = patch.TransferResources(context.Background(), env.ClientDir(), resources, env.ServerConfig, env.Observer)
 	if err != nil {
 		t.Fatalf("test patching: %s: transfer resource: %v", version, err)
 	}
@@ -229,12 +403,12 @@ func testBadPatchVersion(t *testing.T, env *environment, resources client.Resour
 	t.Log("Initializing client contents:")
 	clientFS.Init(env.ClientDir(), t)
 
-	patch, err := env.ServerConfig.GetPatch(version)
+	patch, err := env.ServerConfig.GetPatch(context.Background(), version)
 	if err != nil {
 		t.Fatalf("test patching: bad %s: %v", version, err)
 	}
 
-	err = patch.TransferResources(env.ClientDir(), resources, env.ServerConfig)
+	err = patch.TransferResources(context.Background(), env.ClientDir(), resources, env.ServerConfig, env.Observer)
 	if err == nil {
 		t.Fatalf("test patching: bad %s: transfer resources: did not return an error", version)
 	}
@@ -242,6 +416,20 @@ func testBadPatchVersion(t *testing.T, env *environment, resources client.Resour
 	t.Logf("patch %s correctly returned an error! (%v)", version, err)
 }
 
+func testBadUpdateVersion(t *testing.T, env *environment, version string) {
+	patch, err := env.ServerConfig.GetPatch(context.Background(), version)
+	if err != nil {
+		t.Fatalf("test patching: bad %s: %v", version, err)
+	}
+
+	err = patch.UpdateResources(context.Background(), env.ServerConfig, env.Rejections, env.Downloader, env.Observer)
+	if err == nil {
+		t.Fatalf("test patching: bad %s: update resources: did not return an error", version)
+	}
+
+	t.Logf("patch %s correctly returned an error! (%v)", version, err)
+}
+
 func TestPatching(t *testing.T) {
 	expectedBoot := &ldf.BootConfig{
 		ServerName:      fmt.Sprintf("Server %d", rand.Uint32()),
@@ -260,12 +448,17 @@ func TestPatching(t *testing.T) {
 		additions:    additionsCache{m: make(map[string]struct{})},
 	}
 
-	_, err := env.ServerConfig.GetPatch("v1.0.0")
+	_, err := env.ServerConfig.GetPatch(context.Background(), "v1.0.0")
 	if !errors.Is(err, patch.ErrPatchesUnavailable) {
 		t.Fatalf("test patching: Server.GetPatch did not return patch.ErrPatchesUnavailable: instead: %v", err)
 	}
 
-	go env.PatchServer.ListenAndServe()
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test patching: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
 
 	t.Log("Started test patch server.")
 
@@ -303,6 +496,54 @@ func TestPatching(t *testing.T) {
 	// Test bad version name
 	testBadPatchVersion(t, env, clientResources, "invalid_version", clientFS)
 
+	// Test verified downloads
+	testPatchVersion(t, env, clientResources, "v7.0.0", clientFS, fileSystem{
+		"data/file1": []byte("Test 1"),
+		"data/file2": []byte("Test 2"),
+		"data/file3": []byte("Test 3"),
+	})
+
+	// Test downloads which do not match their checksum
+	testBadUpdateVersion(t, env, "v8.0.0")
+
+	// Test remove directive
+	removalResources := &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}
+
+	testPatchVersion(t, env, removalResources, "v11.0.0", clientFS, fileSystem{
+		"data/file1": []byte("Test 1"),
+		"data/file3": []byte("default data 3"),
+	})
+
+	removed, err := removalResources.Replacements().Get("data/file2")
+	if err != nil {
+		t.Fatalf("test patching: removed resource was not cached: %v", err)
+	}
+
+	if !hasSameContent(removed.Data, clientFS["data/file2"]) {
+		t.Fatalf("test patching: expected cached resource to be `%s` but got `%s`", clientFS["data/file2"], removed.Data)
+	}
+
+	// Test removing nonlocal resources
+	testBadPatchVersion(t, env, clientResources, "v12.0.0", clientFS)
+
+	// Test extract directive
+	testPatchVersion(t, env, clientResources, "v15.0.0", clientFS, fileSystem{
+		"data/file1": []byte("Test 1"),
+		"data/file2": []byte("Test 2"),
+		"data/file3": []byte("default data 3"),
+		"data/file4": []byte("Test 3"),
+	})
+
+	// Test archives with nonlocal entries
+	testBadUpdateVersion(t, env, "v16.0.0")
+
+	if _, err := os.Stat(filepath.Join(env.ServerConfig.DownloadDir(), "v16.0.0", "../../../../slip")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("test patching: nonlocal archive entry was extracted: %v", err)
+	}
+
 	// Test update directives
 	testPatchVersion(t, env, clientResources, "v6.0.0", clientFS, clientFS) // client should remain unchanged
 
@@ -318,3 +559,1477 @@ func TestPatching(t *testing.T) {
 
 	t.Logf("Patch protocol is correct! (\"%s\")", env.ServerConfig.PatchProtocol)
 }
+
+func testResumedPatchVersion(t *testing.T, env *environment, resources client.Resources, version, partial, validator string, clientFS fileSystem, expectedFS fileSystem) {
+	downloadDir := filepath.Join(env.ServerConfig.DownloadDir(), version)
+	err := os.MkdirAll(downloadDir, 0755)
+	if err != nil {
+		t.Fatalf("test resume: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(downloadDir, "a.part"), []byte(partial), 0755)
+	if err != nil {
+		t.Fatalf("test resume: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(downloadDir, "a.part.validator"), []byte(validator), 0755)
+	if err != nil {
+		t.Fatalf("test resume: %v", err)
+	}
+
+	testPatchVersion(t, env, resources, version, clientFS, expectedFS)
+
+	if _, err := os.Stat(filepath.Join(downloadDir, "a.part")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("test resume: expected partial download to be removed: %v", err)
+	}
+}
+
+func TestResumingDownloads(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	clientFS := clientFileSystem()
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	clientResources := &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test resume: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	// The partial contents differ from the served contents, so that the resumed download is distinguishable
+	// from a complete download.
+	testResumedPatchVersion(t, env, clientResources, "v9.0.0", "Resu", patchServerModTime.Format(http.TimeFormat), clientFS, fileSystem{
+		"data/file1": []byte("Resu 1"),
+		"data/file2": []byte("default data 2"),
+		"data/file3": []byte("default data 3"),
+	})
+
+	// A validator which no longer matches should restart the download
+	testResumedPatchVersion(t, env, clientResources, "v9.0.0", "Resu", "\"outdated\"", clientFS, fileSystem{
+		"data/file1": []byte("Test 1"),
+		"data/file2": []byte("default data 2"),
+		"data/file3": []byte("default data 3"),
+	})
+}
+
+func TestDeduplicatedDownloads(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	clientFS := clientFileSystem()
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	clientResources := &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test deduplicated downloads: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	events := &eventRecorder{}
+	env.Observer = events
+
+	// v10.0.0 depends on v9.0.0, which both download "/common/a"
+	testPatchVersion(t, env, clientResources, "v10.0.0", clientFS, fileSystem{
+		"data/file1": []byte("Test 2"),
+		"data/file2": []byte("default data 2"),
+		"data/file3": []byte("default data 3"),
+		"data/file4": []byte("Test 1"),
+		"data/file5": []byte("Test 3"),
+	})
+
+	for _, path := range []string{"/patches/common/a", "/patches/common/b", "/patches/common/c"} {
+		if n := env.Requests(path); n != 1 {
+			t.Errorf("test deduplicated downloads: expected 1 request for \"%s\" but got %d", path, n)
+		}
+	}
+
+	err = checkContents(env.ServerConfig.DownloadDir(), filepath.Join("v9.0.0", "a"), []byte("Test 1"))
+	if err != nil {
+		t.Errorf("test deduplicated downloads: %v", err)
+	}
+
+	expectedEvents := map[patch.EventKind]int{
+		patch.DownloadStarted:   1,
+		patch.FileStarted:       3,
+		patch.FileFinished:      3,
+		patch.DependencyEntered: 1,
+		patch.DirectiveApplied:  3,
+	}
+
+	for kind, expected := range expectedEvents {
+		if n := events.Count(kind); n != expected {
+			t.Errorf("test deduplicated downloads: expected %d %v event(s) but got %d", expected, kind, n)
+		}
+	}
+
+	if n := events.Bytes(); n != int64(len("Test 1")*3) {
+		t.Errorf("test deduplicated downloads: expected %d bytes written but got %d", len("Test 1")*3, n)
+	}
+}
+
+func TestDuplicateDownloadNames(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test duplicate download names: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	dir := filepath.Join(env.ServerConfig.DownloadDir(), "v1.0.0")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("test duplicate download names: %v", err)
+	}
+
+	// "/common/a" and "/common/b" are both saved to "a", so neither is downloaded
+	jobs := []patch.DownloadJob{
+		{Version: "v1.0.0", Path: "/common/a", Filename: filepath.Join(dir, "a")},
+		{Version: "v1.0.0", Path: "/common/b", Filename: filepath.Join(dir, "a")},
+		{Version: "v1.0.0", Path: "/common/c", Filename: filepath.Join(dir, "c")},
+	}
+
+	result := env.Downloader.Download(context.Background(), env.ServerConfig, jobs, nil)
+	if !errors.Is(result.Err(), patch.ErrDuplicateDownload) || len(result.Errors) != 1 {
+		t.Errorf("test duplicate download names: expected a single patch.ErrDuplicateDownload but got %v", result.Err())
+	}
+
+	if result.Downloaded != 1 {
+		t.Errorf("test duplicate download names: expected 1 download but got %d", result.Downloaded)
+	}
+
+	for _, path := range []string{"/patches/common/a", "/patches/common/b"} {
+		if n := env.Requests(path); n != 0 {
+			t.Errorf("test duplicate download names: expected no requests for \"%s\" but got %d", path, n)
+		}
+	}
+
+	if err := checkContents(dir, "c", []byte("Test 3")); err != nil {
+		t.Errorf("test duplicate download names: %v", err)
+	}
+}
+
+func TestCancelledDownloads(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test cancelled downloads: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	p, err := env.ServerConfig.GetPatch(context.Background(), "v10.0.0")
+	if err != nil {
+		t.Fatalf("test cancelled downloads: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel as soon as the first resource starts downloading
+	env.Observer = patch.ObserverFunc(func(event patch.Event) {
+		if event.Kind == patch.FileStarted {
+			cancel()
+		}
+	})
+
+	err = p.UpdateResources(ctx, env.ServerConfig, env.Rejections, env.Downloader, env.Observer)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("test cancelled downloads: expected context.Canceled but got %v", err)
+	}
+
+	err = filepath.WalkDir(env.ServerConfig.DownloadDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(path, ".part") || strings.HasSuffix(path, ".part.validator") {
+			t.Errorf("test cancelled downloads: partial download was not removed: %s", path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("test cancelled downloads: %v", err)
+	}
+}
+
+func TestSignedPatches(t *testing.T) {
+	publicKey, encodedPrivateKey, err := patch.GenerateKey()
+	if err != nil {
+		t.Fatalf("test signed patches: %v", err)
+	}
+
+	privateKey, err := patch.ParsePrivateKey(encodedPrivateKey)
+	if err != nil {
+		t.Fatalf("test signed patches: %v", err)
+	}
+
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	serverFS["/patches/summary.json"] = []byte(`{"currentVersion":"v8.0.0","availableVersions":["v1.0.0","v8.0.0"]}`)
+	serverFS["/patches/summary.json.sig"] = patch.Sign(privateKey, serverFS["/patches/summary.json"])
+	serverFS["/patches/v8.0.0/patch.json.sig"] = patch.Sign(privateKey, serverFS["/patches/v8.0.0/patch.json"])
+	serverFS["/patches/v3.0.0/patch.json.sig"] = patch.Sign(privateKey, serverFS["/patches/v8.0.0/patch.json"]) // Signature of a different document
+
+	// v1.0.0 is signed, but its downloads do not specify a sha256 hash, so they cannot be verified
+	serverFS["/patches/v1.0.0/patch.json.sig"] = patch.Sign(privateKey, serverFS["/patches/v1.0.0/patch.json"])
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	env.ServerConfig.PatchPublicKey = publicKey
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test signed patches: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	summary, err := env.ServerConfig.GetPatchesSummary(context.Background())
+	if err != nil {
+		t.Fatalf("test signed patches: summary: %v", err)
+	}
+
+	if summary.CurrentVersion != "v8.0.0" {
+		t.Fatalf("test signed patches: expected current version \"v8.0.0\" but got \"%s\"", summary.CurrentVersion)
+	}
+
+	_, err = env.ServerConfig.GetPatch(context.Background(), "v8.0.0")
+	if err != nil {
+		t.Fatalf("test signed patches: v8.0.0: %v", err)
+	}
+
+	_, err = env.ServerConfig.GetPatch(context.Background(), "v1.0.0")
+	if !errors.Is(err, patch.ErrChecksumMissing) {
+		t.Errorf("test signed patches: v1.0.0: expected patch.ErrChecksumMissing but got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(env.ServerConfig.DownloadDir(), "v1.0.0", "patch.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("test signed patches: v1.0.0: expected the patch.json to not be saved")
+	}
+
+	_, err = env.ServerConfig.GetPatch(context.Background(), "v2.0.0")
+	if !errors.Is(err, patch.ErrSignatureMissing) {
+		t.Errorf("test signed patches: v2.0.0: expected patch.ErrSignatureMissing but got %v", err)
+	}
+
+	_, err = env.ServerConfig.GetPatch(context.Background(), "v3.0.0")
+	if !errors.Is(err, patch.ErrSignatureInvalid) {
+		t.Errorf("test signed patches: v3.0.0: expected patch.ErrSignatureInvalid but got %v", err)
+	}
+
+	// A saved patch.json which was modified after being verified should be requested again
+	savedPatch := filepath.Join(env.ServerConfig.DownloadDir(), "v8.0.0", "patch.json")
+	err = os.WriteFile(savedPatch, []byte(`{"add":{"dinput8.dll":"dinput8.dll"}}`), 0755)
+	if err != nil {
+		t.Fatalf("test signed patches: %v", err)
+	}
+
+	_, err = env.ServerConfig.GetPatch(context.Background(), "v8.0.0")
+	if err != nil {
+		t.Fatalf("test signed patches: v8.0.0: %v", err)
+	}
+
+	if n := env.Requests("/patches/v8.0.0/patch.json"); n != 2 {
+		t.Errorf("test signed patches: expected modified patch.json to be requested again: got %d request(s)", n)
+	}
+
+	err = checkContents(env.ServerConfig.DownloadDir(), filepath.Join("v8.0.0", "patch.json"), serverFS["/patches/v8.0.0/patch.json"])
+	if err != nil {
+		t.Errorf("test signed patches: %v", err)
+	}
+}
+
+func TestDeltaPatches(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	clientFS := clientFileSystem()
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	clientResources := &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test delta patches: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	expectedFS := fileSystem{
+		"data/file1": []byte("default data 1 (patched)"),
+		"data/file2": []byte("default data 2"),
+		"data/file3": []byte("default data 3"),
+	}
+
+	testPatchVersion(t, env, clientResources, "v13.0.0", clientFS, expectedFS)
+
+	// The client resource is already patched, so the delta must be applied to the cached resource
+	p, err := env.ServerConfig.GetPatch(context.Background(), "v13.0.0")
+	if err != nil {
+		t.Fatalf("test delta patches: %v", err)
+	}
+
+	err = p.TransferResources(context.Background(), env.ClientDir(), clientResources, env.ServerConfig, env.Observer)
+	if err != nil {
+		t.Fatalf("test delta patches: repeated transfer: %v", err)
+	}
+
+	err = checkContents(env.ClientDir(), "data/file1", expectedFS["data/file1"])
+	if err != nil {
+		t.Errorf("test delta patches: repeated transfer: %v", err)
+	}
+
+	// Test deltas which do not match their target hash
+	clientFS.Init(env.ClientDir(), t)
+
+	p, err = env.ServerConfig.GetPatch(context.Background(), "v14.0.0")
+	if err != nil {
+		t.Fatalf("test delta patches: %v", err)
+	}
+
+	err = p.UpdateResources(context.Background(), env.ServerConfig, env.Rejections, env.Downloader, env.Observer)
+	if err != nil {
+		t.Fatalf("test delta patches: v14.0.0: update resources: %v", err)
+	}
+
+	err = p.TransferResources(context.Background(), env.ClientDir(), clientResources, env.ServerConfig, env.Observer)
+	if !errors.Is(err, patch.ErrChecksumMismatch) {
+		t.Fatalf("test delta patches: v14.0.0: expected patch.ErrChecksumMismatch but got %v", err)
+	}
+
+	err = checkContents(env.ClientDir(), "data/file1", clientFS["data/file1"])
+	if err != nil {
+		t.Errorf("test delta patches: v14.0.0: %v", err)
+	}
+}
+
+func resolvedVersions(patches []patch.Patch) []string {
+	versions := []string{}
+	for _, p := range patches {
+		versions = append(versions, p.Version())
+	}
+	return versions
+}
+
+func TestResolvingDependencies(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	clientFS := clientFileSystem()
+
+	serverFS["/patches/v33.0.0/patch.json"] = []byte(`{"depend":["v34.0.0*"]}`)
+	serverFS["/patches/v34.0.0/patch.json"] = []byte(`{"depend":["v35.0.0"]}`)
+	serverFS["/patches/v35.0.0/patch.json"] = []byte(`{"depend":["v36.0.0"]}`)
+	serverFS["/patches/v36.0.0/patch.json"] = []byte(`{}`)
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	clientResources := &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test resolving dependencies: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	ctx := context.Background()
+
+	// v17.0.0* -> v18.0.0* -> v17.0.0*
+	p, err := env.ServerConfig.GetPatch(ctx, "v17.0.0")
+	if err != nil {
+		t.Fatalf("test resolving dependencies: %v", err)
+	}
+
+	_, err = patch.ResolveDependencies(ctx, env.ServerConfig, p)
+
+	cycle := &patch.DependencyCycleError{}
+	if !errors.As(err, &cycle) {
+		t.Fatalf("test resolving dependencies: expected *patch.DependencyCycleError but got %v", err)
+	}
+
+	if expected := "v17.0.0 -> v18.0.0 -> v17.0.0"; strings.Join(cycle.Path, " -> ") != expected {
+		t.Errorf("test resolving dependencies: expected cycle \"%s\" but got \"%s\"", expected, strings.Join(cycle.Path, " -> "))
+	}
+
+	err = p.UpdateResources(ctx, env.ServerConfig, env.Rejections, env.Downloader, env.Observer)
+	if !errors.As(err, &cycle) {
+		t.Errorf("test resolving dependencies: update resources: expected *patch.DependencyCycleError but got %v", err)
+	}
+
+	// Non-recursive dependencies should not include their own dependencies
+	p, err = env.ServerConfig.GetPatch(ctx, "v23.0.0")
+	if err != nil {
+		t.Fatalf("test resolving dependencies: %v", err)
+	}
+
+	dependencies, err := patch.ResolveDependencies(ctx, env.ServerConfig, p)
+	if err != nil {
+		t.Fatalf("test resolving dependencies: %v", err)
+	}
+
+	if expected := "v20.0.0"; strings.Join(resolvedVersions(dependencies), ",") != expected {
+		t.Errorf("test resolving dependencies: expected \"%s\" but got \"%s\"", expected, strings.Join(resolvedVersions(dependencies), ","))
+	}
+
+	// v33.0.0 -> v34.0.0* -> v35.0.0 -> v36.0.0, where v35.0.0 is not suffixed with '*', so its
+	// dependencies are not included
+	p, err = env.ServerConfig.GetPatch(ctx, "v33.0.0")
+	if err != nil {
+		t.Fatalf("test resolving dependencies: %v", err)
+	}
+
+	dependencies, err = patch.ResolveDependencies(ctx, env.ServerConfig, p)
+	if err != nil {
+		t.Fatalf("test resolving dependencies: %v", err)
+	}
+
+	if expected := "v35.0.0,v34.0.0"; strings.Join(resolvedVersions(dependencies), ",") != expected {
+		t.Errorf("test resolving dependencies: expected \"%s\" but got \"%s\"", expected, strings.Join(resolvedVersions(dependencies), ","))
+	}
+
+	// v19.0.0 -> (v20.0.0*, v21.0.0*) -> v22.0.0
+	p, err = env.ServerConfig.GetPatch(ctx, "v19.0.0")
+	if err != nil {
+		t.Fatalf("test resolving dependencies: %v", err)
+	}
+
+	dependencies, err = patch.ResolveDependencies(ctx, env.ServerConfig, p)
+	if err != nil {
+		t.Fatalf("test resolving dependencies: %v", err)
+	}
+
+	if expected := "v22.0.0,v20.0.0,v21.0.0"; strings.Join(resolvedVersions(dependencies), ",") != expected {
+		t.Errorf("test resolving dependencies: expected \"%s\" but got \"%s\"", expected, strings.Join(resolvedVersions(dependencies), ","))
+	}
+
+	err = p.UpdateResources(ctx, env.ServerConfig, env.Rejections, env.Downloader, env.Observer)
+	if err != nil {
+		t.Fatalf("test resolving dependencies: update resources: %v", err)
+	}
+
+	// v22.0.0 adds a resource, so transferring it more than once would fail
+	clientFS.Init(env.ClientDir(), t)
+	err = p.TransferResourcesWithDependencies(ctx, env.ClientDir(), clientResources, env.ServerConfig, env.Observer)
+	if err != nil {
+		t.Fatalf("test resolving dependencies: transfer resources: %v", err)
+	}
+
+	expectedFS := fileSystem{
+		"data/file1": []byte("default data 1"),
+		"data/file2": []byte("Test 2"),
+		"data/file3": []byte("Test 3"),
+		"data/file4": []byte("Test 1"),
+	}
+
+	for path, expectedData := range expectedFS {
+		if err := checkContents(env.ClientDir(), path, expectedData); err != nil {
+			t.Errorf("test resolving dependencies: %v", err)
+		}
+	}
+}
+
+func TestPatchRunners(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	serverFS["/patches/summary.json"] = []byte(`{"runner":"tpp/99","currentVersion":"v1.0.0","availableVersions":["v1.0.0"]}`)
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test patch runners: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	ctx := context.Background()
+
+	_, err = env.ServerConfig.GetPatchesSummary(ctx)
+	if !errors.Is(err, patch.ErrUnsupportedProtocol) {
+		t.Fatalf("test patch runners: summary: expected patch.ErrUnsupportedProtocol but got %v", err)
+	}
+
+	env.ServerConfig.PatchRunner = "tpp/99"
+	_, err = env.ServerConfig.GetPatch(ctx, "v1.0.0")
+	if !errors.Is(err, patch.ErrUnsupportedProtocol) {
+		t.Fatalf("test patch runners: v1.0.0: expected patch.ErrUnsupportedProtocol but got %v", err)
+	}
+
+	created := 0
+	patch.Register("test/1", func(version string) patch.Patch {
+		created++
+		return patch.NewTpp(version)
+	})
+
+	env.ServerConfig.PatchRunner = "test/1"
+	p, err := env.ServerConfig.GetPatch(ctx, "v1.0.0")
+	if err != nil {
+		t.Fatalf("test patch runners: v1.0.0: %v", err)
+	}
+
+	if created != 1 {
+		t.Errorf("test patch runners: expected the registered constructor to be used")
+	}
+
+	if p.Version() != "v1.0.0" {
+		t.Errorf("test patch runners: expected version \"v1.0.0\" but got \"%s\"", p.Version())
+	}
+}
+
+func TestFilePatches(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	serverFS["/patches/summary.json"] = []byte(`{"currentVersion":"v1.0.0","availableVersions":["v1.0.0"]}`)
+
+	clientFS := clientFileSystem()
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	// The patch server is never started, so every resource must be read from disk
+	remoteDir, err := filepath.Abs(filepath.Join(env.Dir, "remote"))
+	if err != nil {
+		t.Fatalf("test file patches: %v", err)
+	}
+	serverFS.Init(remoteDir, t)
+
+	env.ServerConfig.PatchProtocol = "file"
+	env.ServerConfig.Config.PatchServerDir = filepath.Join(remoteDir, "patches")
+
+	clientResources := &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}
+
+	summary, err := env.ServerConfig.GetPatchesSummary(context.Background())
+	if err != nil {
+		t.Fatalf("test file patches: summary: %v", err)
+	}
+
+	if summary.CurrentVersion != "v1.0.0" {
+		t.Errorf("test file patches: expected current version \"v1.0.0\" but got \"%s\"", summary.CurrentVersion)
+	}
+
+	testPatchVersion(t, env, clientResources, "v1.0.0", clientFS, fileSystem{
+		"data/file1": []byte("Test 1"),
+		"data/file2": []byte("Test 2"),
+		"data/file3": []byte("Test 3"),
+	})
+
+	testBadPatchVersion(t, env, clientResources, "v4.0.0", clientFS)
+
+	_, err = env.ServerConfig.GetPatch(context.Background(), "v99.0.0")
+	if err == nil {
+		t.Errorf("test file patches: expected an error for a missing version")
+	}
+}
+
+func TestPatchPlans(t *testing.T) {
+	expectedBoot := ldf.DefaultBootConfig()
+	expectedBoot.ServerName = "Planned Server"
+
+	serverFS := serverFileSystem(expectedBoot)
+	serverFS["/patches/v24.0.0/patch.json"] = []byte(`{
+    "depend": ["v3.0.0"],
+    "download": {
+        "/common/a": "a",
+        "/common/pack.zip": "pack.zip",
+        "/boot.cfg": "boot.cfg"
+    },
+    "extract": {
+        "pack.zip": "pack"
+    },
+    "update": {
+        "boot": "boot.cfg"
+    },
+    "replace": {
+        "a": "data/file4"
+    },
+    "add": {
+        "a": "data/file9"
+    },
+    "remove": [
+        "data/file1"
+    ]
+}`)
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	clientFileSystem().Init(env.ClientDir(), t)
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test patch plans: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	ctx := context.Background()
+
+	p, err := env.ServerConfig.GetPatch(ctx, "v24.0.0")
+	if err != nil {
+		t.Fatalf("test patch plans: %v", err)
+	}
+
+	plan, err := patch.MakePlan(ctx, env.ServerConfig, p, env.ClientDir(), nil, env.Downloader)
+	if err != nil {
+		t.Fatalf("test patch plans: %v", err)
+	}
+
+	if strings.Join(plan.Versions, ",") != "v3.0.0,v24.0.0" {
+		t.Errorf("test patch plans: expected versions [v3.0.0 v24.0.0] but got %v", plan.Versions)
+	}
+
+	if len(plan.Downloads) != 6 {
+		t.Fatalf("test patch plans: expected 6 downloads but got %d", len(plan.Downloads))
+	}
+
+	expectedExtraction := patch.PlannedExtraction{Version: "v24.0.0", Archive: "pack.zip", Directory: "pack"}
+	if len(plan.Extractions) != 1 || plan.Extractions[0] != expectedExtraction {
+		t.Errorf("test patch plans: expected extraction %v but got %v", expectedExtraction, plan.Extractions)
+	}
+
+	size, known := plan.DownloadSize()
+	if !known {
+		t.Errorf("test patch plans: expected every download size to be known: %v", plan.Downloads)
+	}
+
+	// "/common/a", "/common/b", and "/common/c" are each 6 bytes
+	expectedSize := int64(6*4 + len(serverFS["/patches/common/pack.zip"]) + len(serverFS["/patches/boot.cfg"]))
+	if size != expectedSize {
+		t.Errorf("test patch plans: expected download size %d but got %d", expectedSize, size)
+	}
+
+	// "/common/a" is downloaded by both versions, but its size is only requested once
+	if requests := env.Requests("/patches/common/a"); requests != 1 {
+		t.Errorf("test patch plans: expected 1 request for \"/patches/common/a\" but got %d", requests)
+	}
+
+	expectedTransfers := []patch.PlannedTransfer{
+		{Version: "v3.0.0", Directive: "replace", Source: "a", Path: filepath.Clean("data/file1"), Exists: true},
+		{Version: "v3.0.0", Directive: "replace", Source: "b", Path: filepath.Clean("data/file2"), Exists: true},
+		{Version: "v3.0.0", Directive: "add", Source: "c", Path: filepath.Clean("data/file4"), Exists: false},
+		{Version: "v24.0.0", Directive: "replace", Source: "a", Path: filepath.Clean("data/file4"), Exists: true},
+		{Version: "v24.0.0", Directive: "add", Source: "a", Path: filepath.Clean("data/file9"), Exists: false},
+		{Version: "v24.0.0", Directive: "remove", Path: filepath.Clean("data/file1"), Exists: true},
+	}
+
+	if len(plan.Transfers) != len(expectedTransfers) {
+		t.Fatalf("test patch plans: expected %d transfers but got %d: %v", len(expectedTransfers), len(plan.Transfers), plan.Transfers)
+	}
+
+	for i, expected := range expectedTransfers {
+		if plan.Transfers[i] != expected {
+			t.Errorf("test patch plans: expected transfer %v but got %v", expected, plan.Transfers[i])
+		}
+	}
+
+	// Once the patch has been played, the client directory contains its changes, but the plan is
+	// still made against the pristine client which the cached resources are restored to
+	installed := fileSystem{
+		"data/file2": []byte("Test 2"),
+		"data/file4": []byte("Test 1"),
+		"data/file9": []byte("Test 1"),
+	}
+	installed.Init(env.ClientDir(), t)
+
+	clientResources := &resources{
+		replacements: replacementCache{m: map[string]client.Resource{
+			filepath.Clean("data/file1"): {Path: filepath.Clean("data/file1"), Data: []byte("default data 1")},
+			filepath.Clean("data/file2"): {Path: filepath.Clean("data/file2"), Data: []byte("default data 2")},
+		}},
+		additions: additionsCache{m: map[string]struct{}{
+			filepath.Clean("data/file4"): {},
+			filepath.Clean("data/file9"): {},
+		}},
+	}
+
+	installedPlan, err := patch.MakePlan(ctx, env.ServerConfig, p, env.ClientDir(), clientResources, env.Downloader)
+	if err != nil {
+		t.Fatalf("test patch plans: installed: %v", err)
+	}
+
+	if len(installedPlan.Transfers) != len(expectedTransfers) {
+		t.Fatalf("test patch plans: installed: expected %d transfers but got %d: %v", len(expectedTransfers), len(installedPlan.Transfers), installedPlan.Transfers)
+	}
+
+	for i, expected := range expectedTransfers {
+		if installedPlan.Transfers[i] != expected {
+			t.Errorf("test patch plans: installed: expected transfer %v but got %v", expected, installedPlan.Transfers[i])
+		}
+	}
+
+	clientFileSystem().Init(env.ClientDir(), t)
+
+	if plan.BootFile != "boot.cfg" {
+		t.Errorf("test patch plans: expected boot file \"boot.cfg\" but got \"%s\"", plan.BootFile)
+	}
+
+	foundServerName := false
+	for _, change := range plan.BootChanges {
+		if change.Key == "SERVERNAME" && change.New == expectedBoot.ServerName {
+			foundServerName = true
+		}
+	}
+
+	if !foundServerName {
+		t.Errorf("test patch plans: expected SERVERNAME to change to \"%s\": %v", expectedBoot.ServerName, plan.BootChanges)
+	}
+
+	// Planning must not download or transfer anything
+	for _, path := range []string{"v3.0.0/a", "v24.0.0/a", "v24.0.0/boot.cfg"} {
+		if _, err := os.Stat(filepath.Join(env.ServerConfig.DownloadDir(), path)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("test patch plans: expected \"%s\" to not be downloaded", path)
+		}
+	}
+
+	for path, expectedData := range clientFileSystem() {
+		if err := checkContents(env.ClientDir(), path, expectedData); err != nil {
+			t.Errorf("test patch plans: %v", err)
+		}
+	}
+
+	if env.ServerConfig.Config.ServerName == expectedBoot.ServerName {
+		t.Errorf("test patch plans: expected boot.cfg to be unchanged")
+	}
+}
+
+type cancelObserver struct {
+	cancel context.CancelFunc
+}
+
+func (observer cancelObserver) Notify(event patch.Event) {
+	if event.Kind == patch.DirectiveApplied {
+		observer.cancel()
+	}
+}
+
+func TestTransferRollback(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	serverFS["/patches/v25.0.0/patch.json"] = []byte(`{
+    "download": {
+        "/common/a": "a",
+        "/common/b": "b"
+    },
+    "replace": {
+        "a": "data/file1"
+    },
+    "add": {
+        "b": "data/file2"
+    }
+}`)
+	serverFS["/patches/v26.0.0/patch.json"] = []byte(`{
+    "download": {
+        "/common/a": "a",
+        "/common/b": "b"
+    },
+    "replace": {
+        "a": "data/file1"
+    },
+    "add": {
+        "b": "data/file4"
+    }
+}`)
+
+	clientFS := clientFileSystem()
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test transfer rollback: %v", err)
+	}
+
+	go env.PatchServer.Serve(listener)
+
+	clientResources := &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}
+
+	checkUnchanged := func(version string) {
+		t.Helper()
+
+		numEntries, err := countDirectoryContents(env.ClientDir())
+		if err != nil {
+			t.Fatalf("test transfer rollback: %v", err)
+		}
+
+		if numEntries != len(clientFS) {
+			t.Errorf("test transfer rollback: %s: expected %d client entries but got %d", version, len(clientFS), numEntries)
+		}
+
+		for path, expectedData := range clientFS {
+			if err := checkContents(env.ClientDir(), path, expectedData); err != nil {
+				t.Errorf("test transfer rollback: %s: %v", version, err)
+			}
+		}
+
+		if journal, _ := clientResources.Journal().List(); len(journal) > 0 {
+			t.Errorf("test transfer rollback: %s: expected an empty journal but got %v", version, journal)
+		}
+	}
+
+	transfer := func(version string, observer patch.Observer) error {
+		t.Helper()
+		clientFS.Init(env.ClientDir(), t)
+
+		p, err := env.ServerConfig.GetPatch(context.Background(), version)
+		if err != nil {
+			t.Fatalf("test transfer rollback: %s: %v", version, err)
+		}
+
+		err = p.UpdateResources(context.Background(), env.ServerConfig, env.Rejections, env.Downloader, nil)
+		if err != nil {
+			t.Fatalf("test transfer rollback: %s: %v", version, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		if observer == nil {
+			observer = cancelObserver{cancel}
+		}
+
+		return p.TransferResources(ctx, env.ClientDir(), clientResources, env.ServerConfig, observer)
+	}
+
+	// Adding a resource which already exists fails while staging, so nothing is transferred
+	err = transfer("v25.0.0", env.Observer)
+	if err == nil {
+		t.Fatalf("test transfer rollback: v25.0.0: transfer resources: did not return an error")
+	}
+
+	checkUnchanged("v25.0.0")
+
+	if len(clientResources.replacements.m) > 0 {
+		t.Errorf("test transfer rollback: v25.0.0: expected nothing to be cached")
+	}
+
+	// Cancelling after the first transfer has been committed rolls back the committed transfer
+	err = transfer("v26.0.0", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("test transfer rollback: v26.0.0: expected context.Canceled but got %v", err)
+	}
+
+	checkUnchanged("v26.0.0")
+
+	// An interrupted transfer leaves its resources in the journal
+	err = os.WriteFile(filepath.Join(env.ClientDir(), "data", "file1"), []byte("Test 1"), 0755)
+	if err != nil {
+		t.Fatalf("test transfer rollback: %v", err)
+	}
+	clientResources.Journal().Add(filepath.Clean("data/file1"))
+
+	err = client.Rollback(env.ClientDir(), clientResources)
+	if err != nil {
+		t.Fatalf("test transfer rollback: recover: %v", err)
+	}
+
+	checkUnchanged("recover")
+}
+
+func TestPatchServerTransport(t *testing.T) {
+	env, teardown := setup(t, fileSystem{})
+	defer teardown()
+
+	summary := []byte(`{"currentVersion":"v1.0.0","availableVersions":["v1.0.0"]}`)
+
+	failures := 0
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/patches/summary.json", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write(summary)
+	})
+
+	usePatchServer := func(ts *httptest.Server, protocol string) {
+		addr := ts.Listener.Addr().(*net.TCPAddr)
+		env.ServerConfig.PatchProtocol = protocol
+		env.ServerConfig.Config.PatchServerIP = addr.IP.String()
+		env.ServerConfig.Config.PatchServerPort = addr.Port
+	}
+
+	getSummary := func(name string, expectedRequests int, shouldFail bool) {
+		t.Helper()
+
+		requests = 0
+		_, err := env.ServerConfig.GetPatchesSummary(context.Background())
+		if shouldFail && err == nil {
+			t.Errorf("test patch server transport: %s: expected an error", name)
+		}
+
+		if !shouldFail && err != nil {
+			t.Errorf("test patch server transport: %s: %v", name, err)
+		}
+
+		if expectedRequests >= 0 && requests != expectedRequests {
+			t.Errorf("test patch server transport: %s: expected %d requests but got %d", name, expectedRequests, requests)
+		}
+	}
+
+	ts := httptest.NewServer(mux)
+	usePatchServer(ts, "http")
+
+	retries := server.GetTransportConfig().Retries
+
+	failures = retries
+	getSummary("recovered", retries+1, false)
+
+	failures = retries + 1
+	getSummary("exhausted", retries+1, true)
+
+	ts.Close()
+
+	failures = 0
+
+	tlsServer := httptest.NewTLSServer(mux)
+	defer tlsServer.Close()
+	usePatchServer(tlsServer, "https")
+
+	// The test server's certificate is self-signed, so it is only trusted when it is pinned or added as a CA
+	getSummary("untrusted", 0, true)
+
+	env.ServerConfig.PatchFingerprint = server.CertificateFingerprint(tlsServer.Certificate())
+	getSummary("pinned", 1, false)
+
+	env.ServerConfig.PatchFingerprint = strings.Repeat("0", 64)
+	getSummary("mismatched fingerprint", 0, true)
+
+	env.ServerConfig.PatchFingerprint = ""
+	env.ServerConfig.PatchCA = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}))
+	getSummary("certificate authority", 1, false)
+}
+
+func TestSavedPatchesSummary(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	serverFS["/patches/summary.json"] = []byte(`{"currentVersion":"v1.0.0","availableVersions":["v1.0.0"]}`)
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test saved patches summary: %v", err)
+	}
+	go env.PatchServer.Serve(listener)
+
+	getSummary := func(name, expectedVersion string) {
+		t.Helper()
+
+		summary, err := env.ServerConfig.GetPatchesSummary(context.Background())
+		if err != nil {
+			t.Fatalf("test saved patches summary: %s: %v", name, err)
+		}
+
+		if summary.CurrentVersion != expectedVersion {
+			t.Errorf("test saved patches summary: %s: expected current version \"%s\" but got \"%s\"", name, expectedVersion, summary.CurrentVersion)
+		}
+	}
+
+	if _, ok := env.ServerConfig.SavedPatchesSummary(); ok {
+		t.Errorf("test saved patches summary: expected no saved summary before the first request")
+	}
+
+	getSummary("first request", "v1.0.0")
+
+	// The modification time of the test server's files never changes, so the server responds with
+	// 304 Not Modified, and the saved summary is used instead of the changed one
+	serverFS["/patches/summary.json"] = []byte(`{"currentVersion":"v2.0.0","availableVersions":["v1.0.0","v2.0.0"]}`)
+	getSummary("not modified", "v1.0.0")
+
+	if requests := env.Requests("/patches/summary.json"); requests != 2 {
+		t.Errorf("test saved patches summary: expected 2 requests but got %d", requests)
+	}
+
+	env.PatchServer.Shutdown(context.Background())
+
+	_, err = env.ServerConfig.GetPatchesSummary(context.Background())
+	if !errors.Is(err, patch.ErrPatchesUnavailable) {
+		t.Errorf("test saved patches summary: offline: expected patch.ErrPatchesUnavailable but got %v", err)
+	}
+
+	saved, ok := env.ServerConfig.SavedPatchesSummary()
+	if !ok {
+		t.Fatalf("test saved patches summary: offline: expected a saved summary")
+	}
+
+	if saved.CurrentVersion != "v1.0.0" {
+		t.Errorf("test saved patches summary: offline: expected current version \"v1.0.0\" but got \"%s\"", saved.CurrentVersion)
+	}
+
+	// A summary saved from a different patch server is never used
+	env.ServerConfig.Config.PatchServerPort = 3001
+	if _, ok := env.ServerConfig.SavedPatchesSummary(); ok {
+		t.Errorf("test saved patches summary: expected the saved summary to be ignored after the patch server changed")
+	}
+}
+
+func TestPatchMirrors(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	serverFS["/patches/summary.json"] = []byte(`{"currentVersion":"v1.0.0","availableVersions":["v1.0.0"],"mirrors":["http://127.0.0.1:3000/advertised"]}`)
+
+	clientFS := clientFileSystem()
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", env.PatchServer.Addr)
+	if err != nil {
+		t.Fatalf("test patch mirrors: %v", err)
+	}
+	go env.PatchServer.Serve(listener)
+
+	// The primary patch server is always down, so every request must be sent to the mirror
+	failures := 0
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failures++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+
+	addr := primary.Listener.Addr().(*net.TCPAddr)
+	env.ServerConfig.ID = "mirrors"
+	env.ServerConfig.Config.PatchServerPort = addr.Port
+	env.ServerConfig.PatchMirrors = []string{"not a url", "http://127.0.0.1:3000/patches"}
+
+	summary, err := env.ServerConfig.GetPatchesSummary(context.Background())
+	if err != nil {
+		t.Fatalf("test patch mirrors: summary: %v", err)
+	}
+
+	if summary.CurrentVersion != "v1.0.0" {
+		t.Errorf("test patch mirrors: expected current version \"v1.0.0\" but got \"%s\"", summary.CurrentVersion)
+	}
+
+	expectedMirrors := []string{primary.URL + "/patches", "http://127.0.0.1:3000/patches", "http://127.0.0.1:3000/advertised"}
+	if mirrors := env.ServerConfig.Mirrors(); strings.Join(mirrors, " ") != strings.Join(expectedMirrors, " ") {
+		t.Errorf("test patch mirrors: expected mirrors %v but got %v", expectedMirrors, mirrors)
+	}
+
+	// Requests are not retried while other mirrors remain, so the primary patch server is requested once
+	failuresBeforePatching := failures
+	if failuresBeforePatching != 1 {
+		t.Errorf("test patch mirrors: expected 1 request to the primary patch server but got %d", failuresBeforePatching)
+	}
+
+	clientResources := &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}
+
+	testPatchVersion(t, env, clientResources, "v1.0.0", clientFS, fileSystem{
+		"data/file1": []byte("Test 1"),
+		"data/file2": []byte("Test 2"),
+		"data/file3": []byte("Test 3"),
+	})
+
+	// The healthy mirror is remembered, so the primary patch server is not requested again
+	if failures != failuresBeforePatching {
+		t.Errorf("test patch mirrors: expected %d requests to the primary patch server but got %d", failuresBeforePatching, failures)
+	}
+
+	results := env.ServerConfig.CheckMirrors(context.Background())
+	if len(results) != len(expectedMirrors) {
+		t.Fatalf("test patch mirrors: expected %d health checks but got %d", len(expectedMirrors), len(results))
+	}
+
+	if !results[0].Healthy() || results[0].Url != "http://127.0.0.1:3000/patches" {
+		t.Errorf("test patch mirrors: expected \"http://127.0.0.1:3000/patches\" to be the only healthy mirror but got %v", results)
+	}
+
+	for _, result := range results[1:] {
+		if result.Healthy() {
+			t.Errorf("test patch mirrors: expected \"%s\" to be unhealthy", result.Url)
+		}
+	}
+
+	// The token is only sent to advertised mirrors once the summary.json is verified with a pinned key
+	tokens := make(map[string]string)
+	tokensMux := sync.Mutex{}
+	recorder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokensMux.Lock()
+		defer tokensMux.Unlock()
+		tokens[r.URL.Path] = r.Header.Get(server.HEADER_PATCH_TOKEN)
+	}))
+	defer recorder.Close()
+
+	env.ServerConfig.PatchToken = "secret"
+	env.ServerConfig.PatchMirrors = []string{recorder.URL + "/configured"}
+	env.ServerConfig.AdvertisedMirrors = []string{recorder.URL + "/advertised"}
+
+	env.ServerConfig.CheckMirrors(context.Background())
+	if tokens["/configured/summary.json"] != "secret" || tokens["/advertised/summary.json"] != "" {
+		t.Errorf("test patch mirrors: expected the token to only be sent to the configured mirror but got %v", tokens)
+	}
+
+	env.ServerConfig.PatchPublicKey = "pinned"
+	env.ServerConfig.CheckMirrors(context.Background())
+	if tokens["/advertised/summary.json"] != "secret" {
+		t.Errorf("test patch mirrors: expected the token to be sent to the advertised mirror of a verified summary but got %v", tokens)
+	}
+
+	env.ServerConfig.PatchPublicKey = ""
+	env.ServerConfig.PatchMirrors = []string{"not a url", "http://127.0.0.1:3000/patches"}
+	env.ServerConfig.AdvertisedMirrors = []string{"http://127.0.0.1:3000/advertised"}
+
+	// A patch server which does not form a valid URL is not used as a mirror
+	env.ServerConfig.Config.PatchServerIP = "%zz"
+	if mirrors := env.ServerConfig.Mirrors(); strings.Join(mirrors, " ") != strings.Join(expectedMirrors[1:], " ") {
+		t.Errorf("test patch mirrors: invalid patch server: expected mirrors %v but got %v", expectedMirrors[1:], mirrors)
+	}
+}
+
+func TestLintingPatches(t *testing.T) {
+	resources := map[string][]byte{
+		"/common/a":        []byte("Test 1"),
+		"/v1.0.0/boot.cfg": []byte("SERVERNAME=0:Overbuild Universe (US),\nPATCHSERVERIP=0:localhost,\nAUTHSERVERIP=0:localhost,\n"),
+		"/v2.0.0/boot.cfg": []byte("not a boot.cfg"),
+	}
+
+	options := patch.LintOptions{
+		Read: func(path string) ([]byte, error) {
+			data, ok := resources[path]
+			if !ok {
+				return nil, fs.ErrNotExist
+			}
+			return data, nil
+		},
+		VerifyDownloads: true,
+	}
+
+	good := patch.NewTpp("v1.0.0")
+	err := json.Unmarshal([]byte(`{
+		"download": {"/common/a": {"name": "a", "size": 6}, "/v1.0.0/boot.cfg": "boot.cfg"},
+		"update": {"boot": "boot.cfg"},
+		"replace": {"a": "data/file1"}
+	}`), good)
+	if err != nil {
+		t.Fatalf("test linting patches: %v", err)
+	}
+
+	if problems := patch.Lint(good, options); len(problems) > 0 {
+		t.Errorf("test linting patches: v1.0.0: expected no problems but got %v", problems)
+	}
+
+	bad := patch.NewTpp("v2.0.0")
+	err = json.Unmarshal([]byte(`{
+		"depend": ["bad version", "v2.0.0"],
+		"download": {"/common/a": {"name": "../a", "size": 6}, "/common/b": {"name": "b", "size": 1}, "/v2.0.0/boot.cfg": "boot.cfg"},
+		"update": {"boot": "boot.cfg"},
+		"replace": {"c": "data/file1", "b": "data/file1"},
+		"add": {"b": "../outside"},
+		"remove": ["/data/file2"]
+	}`), bad)
+	if err != nil {
+		t.Fatalf("test linting patches: %v", err)
+	}
+
+	expected := []string{
+		`v2.0.0/patch.json: depend[0]: `,
+		`v2.0.0/patch.json: depend[1]: `,
+		`v2.0.0/patch.json: download["/common/a"]: `,
+		`v2.0.0/patch.json: download["/common/b"]: `,
+		`v2.0.0/patch.json: update.boot: `,
+		`v2.0.0/patch.json: replace["c"]: `,
+		`v2.0.0/patch.json: replace["c"]: `,
+		`v2.0.0/patch.json: add["b"]: `,
+		`v2.0.0/patch.json: remove[0]: `,
+	}
+
+	problems := patch.Lint(bad, options)
+	if len(problems) != len(expected) {
+		t.Errorf("test linting patches: v2.0.0: expected %d problems but got %d: %v", len(expected), len(problems), problems)
+	}
+
+	for _, prefix := range expected {
+		found := false
+		for _, problem := range problems {
+			found = found || strings.HasPrefix(problem.String(), prefix)
+		}
+
+		if !found {
+			t.Errorf("test linting patches: v2.0.0: expected a problem starting with %s", prefix)
+		}
+	}
+
+	summary := patch.Summary{CurrentVersion: "v3.0.0", AvailableVersions: []string{"v1.0.0", "v1.0.0", "bad version"}}
+	if problems := summary.Lint(); len(problems) != 3 {
+		t.Errorf("test linting patches: summary: expected 3 problems but got %d: %v", len(problems), problems)
+	}
+}
+
+func TestPatchConflicts(t *testing.T) {
+	serverFS := serverFileSystem(ldf.DefaultBootConfig())
+	serverFS["/patches/summary.json"] = []byte(`{"currentVersion":"v32.0.0","availableVersions":["v30.0.0","v31.0.0","v32.0.0"]}`)
+	serverFS["/patches/v30.0.0/patch.json"] = []byte(`{
+		"download": {"/common/a": "a"},
+		"replace": {"a": "data/file1"},
+		"add": {"a": "data/new"}
+	}`)
+	serverFS["/patches/v31.0.0/patch.json"] = []byte(`{
+		"depend": ["v30.0.0"],
+		"download": {"/common/b": "b"},
+		"add": {"b": "data/new"}
+	}`)
+	serverFS["/patches/v32.0.0/patch.json"] = []byte(`{
+		"depend": ["v30.0.0"],
+		"precedence": ["v30.0.0"],
+		"download": {"/common/b": "b"},
+		"replace": {"b": "data/file1"},
+		"add": {"b": "data/new"}
+	}`)
+
+	clientFS := clientFileSystem()
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	remoteDir, err := filepath.Abs(filepath.Join(env.Dir, "remote"))
+	if err != nil {
+		t.Fatalf("test patch conflicts: %v", err)
+	}
+	serverFS.Init(remoteDir, t)
+
+	env.ServerConfig.PatchProtocol = "file"
+	env.ServerConfig.Config.PatchServerDir = filepath.Join(remoteDir, "patches")
+
+	clientFS.Init(env.ClientDir(), t)
+
+	ctx := context.Background()
+
+	undeclared, err := env.ServerConfig.GetPatch(ctx, "v31.0.0")
+	if err != nil {
+		t.Fatalf("test patch conflicts: v31.0.0: %v", err)
+	}
+
+	err = undeclared.UpdateResources(ctx, env.ServerConfig, env.Rejections, env.Downloader, env.Observer)
+	if !errors.Is(err, patch.ErrConflict) {
+		t.Fatalf("test patch conflicts: v31.0.0: expected patch.ErrConflict but got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(env.ServerConfig.DownloadDir(), "v30.0.0", "a")); err == nil {
+		t.Errorf("test patch conflicts: v31.0.0: expected no resources to be downloaded")
+	}
+
+	declared, err := env.ServerConfig.GetPatch(ctx, "v32.0.0")
+	if err != nil {
+		t.Fatalf("test patch conflicts: v32.0.0: %v", err)
+	}
+
+	plan, err := patch.MakePlan(ctx, env.ServerConfig, declared, env.ClientDir(), nil, env.Downloader)
+	if err != nil {
+		t.Fatalf("test patch conflicts: v32.0.0: plan: %v", err)
+	}
+
+	if len(plan.Conflicts) != 2 {
+		t.Fatalf("test patch conflicts: v32.0.0: expected 2 conflicts but got %d: %v", len(plan.Conflicts), plan.Conflicts)
+	}
+
+	for _, conflict := range plan.Conflicts {
+		if !conflict.Declared || conflict.Fatal() {
+			t.Errorf("test patch conflicts: v32.0.0: expected a declared conflict but got %s", conflict)
+		}
+	}
+
+	err = declared.UpdateResources(ctx, env.ServerConfig, env.Rejections, env.Downloader, env.Observer)
+	if err != nil {
+		t.Fatalf("test patch conflicts: v32.0.0: update resources: %v", err)
+	}
+
+	err = declared.TransferResourcesWithDependencies(ctx, env.ClientDir(), &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}, env.ServerConfig, env.Observer)
+	if err != nil {
+		t.Fatalf("test patch conflicts: v32.0.0: transfer resources: %v", err)
+	}
+
+	for _, path := range []string{"data/file1", "data/new"} {
+		if err := checkContents(env.ClientDir(), path, []byte("Test 2")); err != nil {
+			t.Errorf("test patch conflicts: v32.0.0: %v", err)
+		}
+	}
+}
+
+func TestConditionalPatches(t *testing.T) {
+	// The patch changes the locale, but its blocks must still be evaluated against the locale it was downloaded with
+	boot := ldf.DefaultBootConfig()
+	boot.Locale = "de_DE"
+
+	serverFS := serverFileSystem(boot)
+	serverFS["/patches/summary.json"] = []byte(`{"currentVersion":"v40.0.0","availableVersions":["v40.0.0"]}`)
+	serverFS["/patches/v40.0.0/patch.json"] = []byte(fmt.Sprintf(`{
+		"download": {"/common/a": "a", "/boot.cfg": "boot.cfg"},
+		"replace": {"a": "data/file1"},
+		"update": {"boot": "boot.cfg"},
+		"when": [
+			{"os": ["%s"], "download": {"/common/b": "b"}, "replace": {"b": "data/file2"}},
+			{"locale": ["de_DE"], "download": {"/common/c": "c"}, "add": {"c": "data/locale"}, "replace": {"c": "data/file1"}},
+			{"os": ["plan9"], "download": {"/common/c": "c"}, "replace": {"c": "data/file3"}}
+		]
+	}`, runtime.GOOS))
+
+	clientFS := clientFileSystem()
+
+	env, teardown := setup(t, serverFS)
+	defer teardown()
+
+	remoteDir, err := filepath.Abs(filepath.Join(env.Dir, "remote"))
+	if err != nil {
+		t.Fatalf("test conditional patches: %v", err)
+	}
+	serverFS.Init(remoteDir, t)
+
+	env.ServerConfig.PatchProtocol = "file"
+	env.ServerConfig.Config.PatchServerDir = filepath.Join(remoteDir, "patches")
+
+	clientResources := &resources{
+		replacements: replacementCache{m: make(map[string]client.Resource)},
+		additions:    additionsCache{m: make(map[string]struct{})},
+	}
+
+	p, err := env.ServerConfig.GetPatch(context.Background(), "v40.0.0")
+	if err != nil {
+		t.Fatalf("test conditional patches: %v", err)
+	}
+
+	plan, err := patch.MakePlan(context.Background(), env.ServerConfig, p, env.ClientDir(), nil, env.Downloader)
+	if err != nil {
+		t.Fatalf("test conditional patches: plan: %v", err)
+	}
+
+	if plan.Conditionals != 3 || plan.AppliedConditionals != 1 {
+		t.Errorf("test conditional patches: expected 1 of 3 conditional blocks to apply but got %d of %d", plan.AppliedConditionals, plan.Conditionals)
+	}
+
+	if len(plan.Downloads) != 3 || len(plan.Transfers) != 2 {
+		t.Errorf("test conditional patches: expected 3 downloads and 2 transfers but got %d and %d", len(plan.Downloads), len(plan.Transfers))
+	}
+
+	downloaded := plan.Conditions
+
+	testPatchVersion(t, env, clientResources, "v40.0.0", clientFS, fileSystem{
+		"data/file1": []byte("Test 1"),
+		"data/file2": []byte("Test 2"),
+		"data/file3": []byte("default data 3"),
+	})
+
+	if locale := env.ServerConfig.BootConfig().Locale; locale != "de_DE" {
+		t.Fatalf("test conditional patches: expected the locale to be updated to \"de_DE\" but got \"%s\"", locale)
+	}
+
+	// The updated boot.cfg does not point to the test patch server
+	env.ServerConfig.Config.PatchServerDir = filepath.Join(remoteDir, "patches")
+
+	plan, err = patch.MakePlan(context.Background(), env.ServerConfig, p, env.ClientDir(), clientResources, env.Downloader)
+	if err != nil {
+		t.Fatalf("test conditional patches: installed plan: %v", err)
+	}
+
+	if plan.Conditions != downloaded || plan.AppliedConditionals != 1 {
+		t.Errorf("test conditional patches: installed plan: expected the conditions the patch was downloaded with but got %v with %d applied blocks", plan.Conditions, plan.AppliedConditionals)
+	}
+
+	localized := p.(*patch.Tpp).Effective(patch.Conditions{OS: runtime.GOOS, Locale: "de_DE"})
+	if localized.Add["c"] != "data/locale" || localized.Replace["b"] != "data/file2" || localized.Replace["c"] != "data/file1" || len(localized.Replace) != 2 {
+		t.Errorf("test conditional patches: de_DE: expected the locale and platform blocks to be merged, and the locale block to override \"data/file1\" but got %v", localized.Replace)
+	}
+
+	if _, err := patch.FindConflicts(env.ServerConfig, []patch.Patch{localized}); err != nil {
+		t.Errorf("test conditional patches: de_DE: expected no conflicts but got %v", err)
+	}
+
+	if problems := patch.Lint(p, patch.LintOptions{}); len(problems) > 0 {
+		t.Errorf("test conditional patches: expected no problems but got %v", problems)
+	}
+}
+
+func TestPatchMetadata(t *testing.T) {
+	summary := patch.Summary{}
+	err := json.Unmarshal([]byte(`{
+		"currentVersion": "v3.0.0",
+		"availableVersions": ["v1.0.0", "v2.0.0", "v2.1.0", "v3.0.0"],
+		"versions": {
+			"v2.0.0": {"title": "Second", "mandatory": true, "releaseDate": "2024-05-01"},
+			"v2.1.0": {"title": "Hotfix", "changelog": "- Fixed *everything*", "mandatory": true},
+			"v3.0.0": {"title": "Third", "releaseDate": "yesterday", "downloadSize": 1024}
+		}
+	}`), &summary)
+	if err != nil {
+		t.Fatalf("test patch metadata: %v", err)
+	}
+
+	tests := []struct {
+		installed string
+		expected  string
+	}{
+		{"", "v2.0.0 v2.1.0"},
+		{"v1.0.0", "v2.0.0 v2.1.0"},
+		{"v2.0.0", "v2.1.0"},
+		{"v3.0.0", ""},
+	}
+
+	for _, test := range tests {
+		if pending := strings.Join(summary.PendingMandatory(test.installed), " "); pending != test.expected {
+			t.Errorf("test patch metadata: %s: expected pending mandatory versions \"%s\" but got \"%s\"", test.installed, test.expected, pending)
+		}
+	}
+
+	if date, ok := summary.Versions["v2.0.0"].Released(); !ok || date.Year() != 2024 {
+		t.Errorf("test patch metadata: expected v2.0.0 to be released in 2024")
+	}
+
+	if _, ok := summary.Versions["v3.0.0"].Released(); ok {
+		t.Errorf("test patch metadata: expected an invalid release date for v3.0.0")
+	}
+
+	if problems := summary.Lint(); len(problems) != 1 || problems[0].Location != `versions["v3.0.0"]` {
+		t.Errorf("test patch metadata: expected a single problem with the release date of v3.0.0 but got %v", problems)
+	}
+
+	p := patch.NewTpp("v3.0.0")
+	err = json.Unmarshal([]byte(`{"metadata": {"changelog": "# Third", "mandatory": true}}`), p)
+	if err != nil {
+		t.Fatalf("test patch metadata: %v", err)
+	}
+
+	metadata := patch.MetadataOf(summary, p)
+	if metadata.Title != "Third" || metadata.Changelog != "# Third" || !metadata.Mandatory || metadata.DownloadSize != 1024 {
+		t.Errorf("test patch metadata: expected the patch.json's metadata to be merged into the summary's metadata but got %+v", metadata)
+	}
+}
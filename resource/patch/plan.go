@@ -0,0 +1,365 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/I-Am-Dench/nimbus-launcher/client"
+	"github.com/I-Am-Dench/nimbus-launcher/ldf"
+)
+
+// A resource which is downloaded when the patch is applied.
+type PlannedDownload struct {
+	// The version of the patch which requested the download.
+	Version string `json:"version"`
+
+	// The remote path of the resource, relative to the Remote Patch Directory.
+	Path string `json:"path"`
+
+	// The name the resource is saved as, relative to the Local Patch Directory.
+	Name string `json:"name"`
+
+	// The size of the resource in bytes, or -1 if the size is unknown.
+	Size int64 `json:"size"`
+}
+
+// A downloaded archive which is unpacked when the patch is applied.
+type PlannedExtraction struct {
+	// The version of the patch which requested the extraction.
+	Version string `json:"version"`
+
+	// The archive, and the directory it is unpacked into, both relative to the Local Patch Directory.
+	Archive   string `json:"archive"`
+	Directory string `json:"directory"`
+}
+
+// A change made to a single client resource when the patch is applied.
+type PlannedTransfer struct {
+	// The version of the patch which makes the change.
+	Version string `json:"version"`
+
+	// One of "replace", "delta", "add", or "remove".
+	Directive string `json:"directive"`
+
+	// The downloaded resource, relative to the Local Patch Directory. Source is empty for removals.
+	Source string `json:"source,omitempty"`
+
+	// The client resource, relative to the client directory.
+	Path string `json:"path"`
+
+	// True if the client resource exists before the change is made, including resources which
+	// are added by an earlier change within the same plan. Transfers are applied to the pristine
+	// client, so resources which were replaced or added by a previous transfer are planned as
+	// they were before that transfer.
+	Exists bool `json:"exists"`
+}
+
+// A boot.cfg field which is changed when the patch is applied.
+type BootChange struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// Describes everything a patch, along with its dependencies, does when it is applied.
+type Plan struct {
+	// The version of the planned patch.
+	Version string `json:"version"`
+
+	// The versions which are applied, in order, where the last version is Version.
+	Versions []string `json:"versions"`
+
+	Downloads   []PlannedDownload   `json:"downloads"`
+	Extractions []PlannedExtraction `json:"extractions"`
+	Transfers   []PlannedTransfer   `json:"transfers"`
+
+	// The boot.cfg file which replaces the server's boot.cfg, relative to the Local Patch Directory.
+	BootFile string `json:"bootFile,omitempty"`
+
+	// The changes made by BootFile. If BootFile could not be read before it is downloaded,
+	// BootChanges is nil.
+	BootChanges []BootChange `json:"bootChanges,omitempty"`
+
+	// The patch protocol the server is updated to, if any.
+	Protocol string `json:"protocol,omitempty"`
+
+	// The platform and locale which the conditional directives of Version are evaluated against. The
+	// directives of each applied version are evaluated against the conditions it was downloaded with,
+	// which are only different from Conditions if the version was downloaded before the server's
+	// boot.cfg was changed.
+	Conditions Conditions `json:"conditions"`
+
+	// The number of conditional blocks within the applied versions, and the number of those blocks
+	// which match their version's conditions.
+	Conditionals        int `json:"conditionals"`
+	AppliedConditionals int `json:"appliedConditionals"`
+
+	// The client resources which are changed by more than one of the applied versions.
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+
+	server          Server
+	clientDirectory string
+	clientResources client.Resources
+	resources       map[string]bool
+}
+
+// Returns the conditions which the conditional directives of the version are evaluated against.
+func (plan *Plan) conditionsFor(version string) Conditions {
+	if plan.server == nil {
+		return plan.Conditions
+	}
+	return conditionsFor(plan.server, version)
+}
+
+// Patches which implement Planner can describe their changes within a Plan without
+// downloading or transferring any resources.
+type Planner interface {
+	PlanResources(ctx context.Context, server Server, plan *Plan) error
+}
+
+// Patches which implement TransferPlanner can describe their transfers within a Plan without making
+// any requests to the server.
+type TransferPlanner interface {
+	PlanTransfers(plan *Plan) error
+}
+
+// Returns the plan for applying the patch, and its resolved dependencies, to the clientDirectory.
+// The replaced and added resources cached within clientResources, which may be nil, are restored
+// before the patch is transferred, so the plan is made against the client as it was before them.
+//
+// Nothing is downloaded or changed while planning, although the sizes of resources are requested from
+// the server when they are not specified by the patch. The sizes are requested at once, using at most
+// downloader.Concurrency requests, and are -1 if they are still unknown.
+func MakePlan(ctx context.Context, server Server, p Patch, clientDirectory string, clientResources client.Resources, downloader *Downloader) (*Plan, error) {
+	plan := &Plan{
+		Version:     p.Version(),
+		Versions:    []string{},
+		Downloads:   []PlannedDownload{},
+		Extractions: []PlannedExtraction{},
+		Transfers:   []PlannedTransfer{},
+
+		Conditions: conditionsFor(server, p.Version()),
+
+		server:          server,
+		clientDirectory: clientDirectory,
+		clientResources: clientResources,
+		resources:       make(map[string]bool),
+	}
+
+	dependencies, err := ResolveDependencies(ctx, server, p)
+	if err != nil {
+		return nil, &PatchError{err}
+	}
+
+	for _, dependency := range append(dependencies, p) {
+		planner, ok := dependency.(Planner)
+		if !ok {
+			return nil, &PatchError{fmt.Errorf("%s: patch cannot be planned", dependency.Version())}
+		}
+
+		plan.Versions = append(plan.Versions, dependency.Version())
+
+		err := planner.PlanResources(ctx, server, plan)
+		if err != nil {
+			return nil, &PatchError{fmt.Errorf("%s: %w", dependency.Version(), err)}
+		}
+	}
+
+	plan.Conflicts = findConflicts(plan.Transfers, append(dependencies, p))
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	plan.requestSizes(ctx, server, downloader)
+
+	return plan, nil
+}
+
+// Returns the total size of the plan's downloads, and whether the size of every download is known.
+func (plan *Plan) DownloadSize() (int64, bool) {
+	size := int64(0)
+	known := true
+
+	for _, download := range plan.Downloads {
+		if download.Size < 0 {
+			known = false
+			continue
+		}
+
+		size += download.Size
+	}
+
+	return size, known
+}
+
+// Returns the planned transfers made by the directive.
+func (plan *Plan) TransfersOf(directive string) []PlannedTransfer {
+	transfers := []PlannedTransfer{}
+	for _, transfer := range plan.Transfers {
+		if transfer.Directive == directive {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers
+}
+
+// Adds the download to the plan. If the entry does not specify a size, the size is -1 until it is
+// requested by plan.requestSizes.
+func (plan *Plan) addDownload(version, path string, entry DownloadEntry) error {
+	name, err := downloadName(path, entry)
+	if err != nil {
+		return err
+	}
+
+	size := entry.Size
+	if size <= 0 {
+		size = -1
+	}
+
+	plan.Downloads = append(plan.Downloads, PlannedDownload{
+		Version: version,
+		Path:    path,
+		Name:    name,
+		Size:    size,
+	})
+
+	return nil
+}
+
+// Requests the size of each of the plan's downloads whose size is unknown.
+func (plan *Plan) requestSizes(ctx context.Context, server Server, downloader *Downloader) {
+	paths := []string{}
+	seen := make(map[string]bool)
+
+	for _, download := range plan.Downloads {
+		if download.Size < 0 && !seen[download.Path] {
+			seen[download.Path] = true
+			paths = append(paths, download.Path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return
+	}
+
+	sizes := downloader.Sizes(ctx, server, paths)
+	for i, download := range plan.Downloads {
+		if download.Size < 0 {
+			plan.Downloads[i].Size = sizes[download.Path]
+		}
+	}
+}
+
+func (plan *Plan) addExtraction(version, archive, directory string) {
+	if len(directory) == 0 {
+		directory = "."
+	}
+
+	plan.Extractions = append(plan.Extractions, PlannedExtraction{
+		Version:   version,
+		Archive:   archive,
+		Directory: directory,
+	})
+}
+
+// Returns true if the resource is within the pristine client, i.e. the client directory once every
+// cached replacement has been restored and every cached addition has been removed.
+func (plan *Plan) pristineContains(resourceName string) bool {
+	if plan.clientResources != nil {
+		if plan.clientResources.Replacements().Has(resourceName) {
+			return true
+		}
+
+		if plan.clientResources.Additions().Has(resourceName) {
+			return false
+		}
+	}
+
+	return client.Contains(plan.clientDirectory, resourceName)
+}
+
+// Adds the transfer to the plan, where the resource exists if it is within the pristine client, or if
+// it has been created by an earlier transfer. If the plan has no client directory, the existence of
+// resources is only known for resources changed by earlier transfers.
+func (plan *Plan) addTransfer(version, directive, source, resourceName string) {
+	exists, ok := plan.resources[resourceName]
+	if !ok && len(plan.clientDirectory) > 0 {
+		exists = plan.pristineContains(resourceName)
+	}
+
+	plan.Transfers = append(plan.Transfers, PlannedTransfer{
+		Version:   version,
+		Directive: directive,
+		Source:    source,
+		Path:      resourceName,
+		Exists:    exists,
+	})
+
+	plan.resources[resourceName] = directive != "remove"
+}
+
+// Returns the Content-Length of the remote resource from a HEAD request, or -1 if the length is unknown.
+func remoteSize(ctx context.Context, server Server, path string) int64 {
+	response, err := server.RemoteHead(ctx, path)
+	if err != nil {
+		return -1
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return -1
+	}
+
+	return response.ContentLength
+}
+
+// Returns the fields of a marshalled boot.cfg mapped to their values.
+func bootFields(config *ldf.BootConfig) ([]string, map[string]string, error) {
+	keys := []string{}
+	fields := make(map[string]string)
+	if config == nil {
+		return keys, fields, nil
+	}
+
+	data, err := ldf.MarshalLines(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, line := range strings.Split(string(data), ",\n") {
+		key, value, _ := strings.Cut(line, "=")
+		if _, typedValue, ok := strings.Cut(value, ":"); ok {
+			value = typedValue
+		}
+
+		keys = append(keys, key)
+		fields[key] = value
+	}
+
+	return keys, fields, nil
+}
+
+// Returns the fields which differ between the old and new boot.cfg, in the order they are marshalled.
+func diffBootConfigs(old, new *ldf.BootConfig) ([]BootChange, error) {
+	_, oldFields, err := bootFields(old)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, newFields, err := bootFields(new)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []BootChange{}
+	for _, key := range keys {
+		if oldFields[key] != newFields[key] {
+			changes = append(changes, BootChange{Key: key, Old: oldFields[key], New: newFields[key]})
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,82 @@
+package patch
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The runner used by servers whose summary.json does not specify a runner.
+const DefaultRunner = "tpp/1"
+
+// Returns a new, empty patch with the specified version. The patch's directives are
+// unmarshaled into the returned value.
+type Constructor func(version string) Patch
+
+var (
+	runnersMux sync.RWMutex
+	runners    = make(map[string]Constructor)
+)
+
+// Makes a patch runner available by the provided name. If Register is called twice with the same
+// name, or if constructor is nil, Register panics.
+func Register(runner string, constructor Constructor) {
+	runnersMux.Lock()
+	defer runnersMux.Unlock()
+
+	if constructor == nil {
+		panic("patch: Register constructor is nil")
+	}
+
+	if _, ok := runners[runner]; ok {
+		panic(fmt.Sprintf("patch: Register called twice for runner \"%s\"", runner))
+	}
+
+	runners[runner] = constructor
+}
+
+// Returns true if a runner has been registered with the name. An empty name refers to DefaultRunner.
+func Supports(runner string) bool {
+	if len(runner) == 0 {
+		runner = DefaultRunner
+	}
+
+	runnersMux.RLock()
+	defer runnersMux.RUnlock()
+
+	_, ok := runners[runner]
+	return ok
+}
+
+// Returns a sorted list of the names of every registered runner.
+func Runners() []string {
+	runnersMux.RLock()
+	defer runnersMux.RUnlock()
+
+	names := []string{}
+	for name := range runners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Creates a new patch using the constructor registered for runner. An empty runner refers to DefaultRunner.
+//
+// If no runner has been registered with the name, ErrUnsupportedProtocol is returned.
+func New(runner, version string) (Patch, error) {
+	if len(runner) == 0 {
+		runner = DefaultRunner
+	}
+
+	runnersMux.RLock()
+	constructor, ok := runners[runner]
+	runnersMux.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w \"%s\"", ErrUnsupportedProtocol, runner)
+	}
+
+	return constructor(version), nil
+}
@@ -94,3 +94,25 @@ func (rejections *RejectionList) IsRejected(server Server, version string) bool
 
 	return false
 }
+
+// Returns the IDs of every server which has rejected versions.
+func (rejections *RejectionList) ServerIds() []string {
+	ids := []string{}
+	for id := range rejections.m {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Removes the rejected versions of every server in ids.
+func (rejections *RejectionList) Forget(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	for _, id := range ids {
+		delete(rejections.m, id)
+	}
+
+	return rejections.Save()
+}
@@ -1,8 +1,12 @@
 package patch
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 type Remote interface {
-	GetPatch(version string) Patch
-	RemoteGet(elem ...string) (*http.Response, error)
+	GetPatch(ctx context.Context, version string) Patch
+	RemoteGet(ctx context.Context, elem ...string) (*http.Response, error)
+	RemoteGetWithHeader(ctx context.Context, header http.Header, elem ...string) (*http.Response, error)
 }
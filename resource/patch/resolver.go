@@ -0,0 +1,195 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// A single entry within the depend directive.
+type Dependency struct {
+	Version string
+
+	// True if the dependency's own dependencies must also be resolved, i.e. the version
+	// was suffixed with '*'.
+	Recursive bool
+}
+
+// Parses a single entry within the depend directive.
+func ParseDependency(s string) Dependency {
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) > 0 && trimmed[len(trimmed)-1] == '*' {
+		return Dependency{Version: strings.TrimSpace(trimmed[:len(trimmed)-1]), Recursive: true}
+	}
+	return Dependency{Version: trimmed}
+}
+
+// Patches which implement Dependent declare the patch versions they depend on.
+type Dependent interface {
+	// Returns the patch's direct dependencies in the order they were declared.
+	DependsOn() []Dependency
+}
+
+type DependencyCycleError struct {
+	// The versions which form the cycle, where the first and last versions are the same.
+	Path []string
+}
+
+func (err *DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(err.Path, " -> "))
+}
+
+type resolver struct {
+	ctx    context.Context
+	server Server
+
+	patches map[string]Patch
+
+	// Versions whose dependencies are also resolved.
+	recursive map[string]bool
+
+	visiting map[string]bool
+	visited  map[string]bool
+	stack    []string
+	order    []Patch
+}
+
+func dependsOn(p Patch) []Dependency {
+	if dependent, ok := p.(Dependent); ok {
+		return dependent.DependsOn()
+	}
+	return nil
+}
+
+func (r *resolver) get(version string) (Patch, error) {
+	if p, ok := r.patches[version]; ok {
+		return p, nil
+	}
+
+	p, err := r.server.GetPatch(r.ctx, version)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve patch dependency \"%s\": %w", version, err)
+	}
+
+	r.patches[version] = p
+	return p, nil
+}
+
+// Marks the version as recursive, along with every version reached from it through a dependency
+// suffixed with '*'.
+func (r *resolver) markRecursive(version string) error {
+	if r.recursive[version] {
+		return nil
+	}
+	r.recursive[version] = true
+
+	p, err := r.get(version)
+	if err != nil {
+		return err
+	}
+
+	for _, dependency := range dependsOn(p) {
+		if len(dependency.Version) == 0 || !dependency.Recursive {
+			continue
+		}
+
+		if err := r.markRecursive(dependency.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the dependencies of the version which must be resolved.
+func (r *resolver) edges(version string, root bool) []Dependency {
+	if !root && !r.recursive[version] {
+		return nil
+	}
+
+	dependencies := []Dependency{}
+	for _, dependency := range dependsOn(r.patches[version]) {
+		if len(dependency.Version) > 0 {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	return dependencies
+}
+
+func (r *resolver) visit(version string, root bool) error {
+	if r.visiting[version] {
+		start := 0
+		for i, v := range r.stack {
+			if v == version {
+				start = i
+				break
+			}
+		}
+
+		path := append([]string{}, r.stack[start:]...)
+		return &DependencyCycleError{Path: append(path, version)}
+	}
+
+	if r.visited[version] {
+		return nil
+	}
+
+	if _, err := r.get(version); err != nil {
+		return err
+	}
+
+	r.visiting[version] = true
+	r.stack = append(r.stack, version)
+
+	for _, dependency := range r.edges(version, root) {
+		if err := r.visit(dependency.Version, false); err != nil {
+			return err
+		}
+	}
+
+	r.stack = r.stack[:len(r.stack)-1]
+	r.visiting[version] = false
+	r.visited[version] = true
+
+	if !root {
+		r.order = append(r.order, r.patches[version])
+	}
+
+	return nil
+}
+
+// Returns every patch which the root patch depends on, where each version appears once, and
+// every patch appears after all of the patches it depends on.
+//
+// A dependency suffixed with '*' has its own dependencies included, and those which are also
+// suffixed with '*' are expanded in turn. Otherwise, only the dependency itself is included.
+//
+// If the dependencies form a cycle, a *DependencyCycleError containing the cycle is returned.
+func ResolveDependencies(ctx context.Context, server Server, root Patch) ([]Patch, error) {
+	r := &resolver{
+		ctx:    ctx,
+		server: server,
+
+		patches:   map[string]Patch{root.Version(): root},
+		recursive: make(map[string]bool),
+
+		visiting: make(map[string]bool),
+		visited:  make(map[string]bool),
+	}
+
+	for _, dependency := range r.edges(root.Version(), true) {
+		if !dependency.Recursive {
+			continue
+		}
+
+		if err := r.markRecursive(dependency.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.visit(root.Version(), true); err != nil {
+		return nil, err
+	}
+
+	return r.order, nil
+}
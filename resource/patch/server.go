@@ -1,6 +1,7 @@
 package patch
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/I-Am-Dench/nimbus-launcher/ldf"
@@ -14,11 +15,20 @@ type Server interface {
 	DownloadDir() string
 
 	// Returns the patch from the server corresponding to the version.
-	GetPatch(version string) (Patch, error)
+	GetPatch(ctx context.Context, version string) (Patch, error)
 
 	// Makes an HTTP request to the server where the parameter, elem, contains the components
 	// which are appended to the requested path.
-	RemoteGet(elem ...string) (*http.Response, error)
+	RemoteGet(ctx context.Context, elem ...string) (*http.Response, error)
+
+	// Same as RemoteGet, but each of the values in header are added to the request.
+	RemoteGetWithHeader(ctx context.Context, header http.Header, elem ...string) (*http.Response, error)
+
+	// Same as RemoteGet, but sends a HEAD request.
+	RemoteHead(ctx context.Context, elem ...string) (*http.Response, error)
+
+	// Returns the current contents of the server's boot.cfg.
+	BootConfig() *ldf.BootConfig
 
 	// Updates contents of the server's boot.cfg.
 	SetBootConfig(*ldf.BootConfig) error
@@ -0,0 +1,79 @@
+package patch
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// The suffix of a detached signature file. The signature of "patch.json" is located at "patch.json.sig".
+const SignatureSuffix = ".sig"
+
+// Returns a new Ed25519 key pair encoded in base64.
+func GenerateKey() (publicKey, privateKey string, err error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(public), base64.StdEncoding.EncodeToString(private), nil
+}
+
+// Decodes a base64 encoded Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: expected %d byte(s) but got %d", ed25519.PublicKeySize, len(data))
+	}
+
+	return ed25519.PublicKey(data), nil
+}
+
+// Decodes a base64 encoded Ed25519 private key.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	if len(data) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key: expected %d byte(s) but got %d", ed25519.PrivateKeySize, len(data))
+	}
+
+	return ed25519.PrivateKey(data), nil
+}
+
+// Returns the base64 encoded signature of data, which is the contents of a detached signature file.
+func Sign(privateKey ed25519.PrivateKey, data []byte) []byte {
+	signature := ed25519.Sign(privateKey, data)
+	return []byte(base64.StdEncoding.EncodeToString(signature))
+}
+
+// Checks the contents of a detached signature file against data, where publicKey is a
+// base64 encoded Ed25519 public key.
+//
+// Returns ErrSignatureInvalid if the signature is malformed or does not match.
+func VerifySignature(publicKey string, data, signature []byte) error {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+
+	if !ed25519.Verify(key, data, decoded) {
+		return ErrSignatureInvalid
+	}
+
+	return nil
+}
@@ -1,6 +1,129 @@
 package patch
 
+import (
+	"sort"
+	"time"
+)
+
 type Summary struct {
+	// The name of the patch runner which the server's patches are written for (e.g. "tpp/1"). If empty,
+	// DefaultRunner is used.
+	Runner string `json:"runner,omitempty"`
+
 	CurrentVersion    string   `json:"currentVersion"`
 	AvailableVersions []string `json:"availableVersions"`
+
+	// Base URLs of Remote Patch Directories which serve the same patches as the server.
+	Mirrors []string `json:"mirrors,omitempty"`
+
+	// Maps versions to their metadata. Versions are not required to have metadata.
+	Versions map[string]Metadata `json:"versions,omitempty"`
+}
+
+// The date formats accepted by Metadata.ReleaseDate.
+var releaseDateLayouts = []string{time.DateOnly, time.RFC3339}
+
+// Describes a single version of the server's patches.
+type Metadata struct {
+	Title string `json:"title,omitempty"`
+
+	// The version's release notes, formatted as Markdown.
+	Changelog string `json:"changelog,omitempty"`
+
+	// The date the version was released, formatted as either "2006-01-02" or RFC 3339.
+	ReleaseDate string `json:"releaseDate,omitempty"`
+
+	// The total size, in bytes, of the version's downloads.
+	DownloadSize int64 `json:"downloadSize,omitempty"`
+
+	// If true, the version must be installed before the client can be played.
+	Mandatory bool `json:"mandatory,omitempty"`
+}
+
+// Returns the parsed release date, and whether the release date is set and valid.
+func (metadata Metadata) Released() (time.Time, bool) {
+	for _, layout := range releaseDateLayouts {
+		if date, err := time.Parse(layout, metadata.ReleaseDate); err == nil {
+			return date, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Returns the metadata with the fields which are set within other replacing its fields. A version
+// is mandatory if either metadata is mandatory.
+func (metadata Metadata) merge(other Metadata) Metadata {
+	if len(other.Title) > 0 {
+		metadata.Title = other.Title
+	}
+
+	if len(other.Changelog) > 0 {
+		metadata.Changelog = other.Changelog
+	}
+
+	if len(other.ReleaseDate) > 0 {
+		metadata.ReleaseDate = other.ReleaseDate
+	}
+
+	if other.DownloadSize > 0 {
+		metadata.DownloadSize = other.DownloadSize
+	}
+
+	metadata.Mandatory = metadata.Mandatory || other.Mandatory
+	return metadata
+}
+
+// Patches which implement Describer include their own metadata, e.g. within their patch.json.
+type Describer interface {
+	Describe() Metadata
+}
+
+// Returns the metadata of the patch, where the metadata included by the patch takes precedence over
+// the summary's metadata for the patch's version.
+func MetadataOf(summary Summary, p Patch) Metadata {
+	metadata := summary.Versions[p.Version()]
+	if describer, ok := p.(Describer); ok {
+		metadata = metadata.merge(describer.Describe())
+	}
+	return metadata
+}
+
+// Returns every version listed within the summary, including the current version if it is not
+// listed as an available version.
+func (summary Summary) Listed() []string {
+	versions := []string{}
+	hasCurrent := false
+
+	for _, version := range summary.AvailableVersions {
+		versions = append(versions, version)
+		hasCurrent = hasCurrent || version == summary.CurrentVersion
+	}
+
+	if !hasCurrent && len(summary.CurrentVersion) > 0 {
+		versions = append(versions, summary.CurrentVersion)
+	}
+
+	return versions
+}
+
+// Returns the mandatory versions which are greater than the installed version, from least to greatest.
+// If nothing is installed, every mandatory version is returned.
+func (summary Summary) PendingMandatory(installed string) []string {
+	pending := []string{}
+	for _, version := range summary.Listed() {
+		if !summary.Versions[version].Mandatory || ValidateVersionName(version) != nil {
+			continue
+		}
+
+		if len(installed) == 0 || CompareVersions(version, installed) > 0 {
+			pending = append(pending, version)
+		}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return CompareVersions(pending[i], pending[j]) < 0
+	})
+
+	return pending
 }
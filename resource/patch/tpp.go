@@ -1,21 +1,38 @@
 package patch
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
 
 	"github.com/I-Am-Dench/nimbus-launcher/client"
 	"github.com/I-Am-Dench/nimbus-launcher/ldf"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch/delta"
 )
 
-type Dependent interface {
-	GetDependencies(server Server, recursive ...bool) ([]Patch, error)
+// The client resource patched by a delta, and the SHA-256 hash of the patched resource.
+type DeltaEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// The directives of a Tpp which change client resources, and which may be conditional. See Conditional.
+type Directives struct {
+	Download map[string]DownloadEntry `json:"download,omitempty"`
+
+	// Maps the name of a downloaded archive to the directory it is unpacked into, where both
+	// are relative to the Local Patch Directory.
+	Extract map[string]string `json:"extract,omitempty"`
+
+	Replace map[string]string `json:"replace,omitempty"`
+	Add     map[string]string `json:"add,omitempty"`
+	Remove  []string          `json:"remove,omitempty"`
+
+	Delta map[string]DeltaEntry `json:"delta,omitempty"`
 }
 
 // See PATCHING.md
@@ -24,15 +41,25 @@ type Tpp struct {
 
 	Dependencies []string `json:"depend,omitempty"`
 
-	Download map[string]string `json:"download,omitempty"`
+	Directives
 
 	Update struct {
 		Boot     string `json:"boot,omitempty"`
 		Protocol string `json:"protocol,omitempty"`
 	} `json:"update,omitempty"`
 
-	Replace map[string]string `json:"replace,omitempty"`
-	Add     map[string]string `json:"add,omitempty"`
+	// The versions, within the same dependency set, whose changes are intentionally overridden by the patch.
+	Precedence []string `json:"precedence,omitempty"`
+
+	// Directives which are only applied on certain platforms or with certain locales.
+	When []Conditional `json:"when,omitempty"`
+
+	// Describes the patch. Fields which are set take precedence over the summary.json's metadata for the version.
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+func init() {
+	Register("tpp/1", NewTpp)
 }
 
 func NewTpp(version string) Patch {
@@ -43,107 +70,108 @@ func (patch *Tpp) Version() string {
 	return patch.version
 }
 
-func (patch *Tpp) doDownloads(server Server) error {
-	log.Println("Starting downloads...")
-	downloadPath := filepath.Join(server.DownloadDir(), patch.version)
-	os.MkdirAll(downloadPath, 0755)
-
-	for path, name := range patch.Download {
-		if len(path) > 0 && len(name) == 0 {
-			name = filepath.Base(path)
-		}
-
-		if len(name) == 0 {
-			return &PatchError{errors.New("invalid download name: name is empty")}
-		}
+func (patch *Tpp) Describe() Metadata {
+	if patch.Metadata == nil {
+		return Metadata{}
+	}
+	return *patch.Metadata
+}
 
-		if !filepath.IsLocal(name) {
-			return &PatchError{fmt.Errorf("invalid download name \"%s\": name is nonlocal", name)}
+func (patch *Tpp) Overrides(version string) bool {
+	for _, overridden := range patch.Precedence {
+		if overridden == version {
+			return true
 		}
+	}
+	return false
+}
 
-		response, err := server.RemoteGet(path)
-		if err != nil {
-			return &PatchError{fmt.Errorf("could not get url: %w", err)}
-		}
-		defer response.Body.Close()
+func (patch *Tpp) DownloadJobs(server Server, rejections *RejectionList) ([]DownloadJob, error) {
+	conditions := conditionsFor(server, patch.version)
+	patch = patch.Effective(conditions)
 
-		if response.StatusCode == http.StatusUnauthorized {
-			return &PatchError{ErrPatchesUnauthorized}
-		}
+	if rejections.IsRejected(server, patch.version) {
+		return nil, &PatchError{fmt.Errorf("\"%s\" is rejected", patch.version)}
+	}
 
-		if response.StatusCode >= 400 {
-			return &PatchError{fmt.Errorf("invalid response status code from server: %d", response.StatusCode)}
-		}
+	if err := ValidateVersionName(patch.version); err != nil {
+		return nil, &PatchError{err}
+	}
 
-		file, err := os.OpenFile(filepath.Join(downloadPath, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
-		if err != nil {
-			return &PatchError{fmt.Errorf("could not open file in download directory: %w", err)}
-		}
-		defer file.Close()
+	downloadPath := filepath.Join(server.DownloadDir(), patch.version)
+	os.MkdirAll(downloadPath, 0755)
 
-		_, err = io.Copy(file, response.Body)
-		if err != nil {
-			return &PatchError{fmt.Errorf("could not save download \"%s\" to \"%s\": %w", path, name, err)}
-		}
+	if err := saveConditions(server, patch.version, conditions); err != nil {
+		return nil, &PatchError{err}
 	}
 
-	return nil
+	return newDownloadJobs(patch.version, downloadPath, patch.Download)
 }
 
-func (patch *Tpp) DownloadResources(server Server, rejections *RejectionList) error {
-	if rejections.IsRejected(server, patch.version) {
-		return &PatchError{fmt.Errorf("\"%s\" is rejected", patch.version)}
+func (patch *Tpp) DownloadResources(ctx context.Context, server Server, rejections *RejectionList, downloader *Downloader, observer Observer) error {
+	jobs, err := patch.DownloadJobs(server, rejections)
+	if err != nil {
+		return err
 	}
 
-	if err := ValidateVersionName(patch.version); err != nil {
-		return &PatchError{err}
+	log.Println("Starting downloads...")
+	err = downloader.Download(ctx, server, jobs, observer).Err()
+	if err != nil {
+		return err
 	}
 
-	return patch.doDownloads(server)
+	return patch.ExtractResources(ctx, server, observer)
 }
 
-func (patch *Tpp) parseDependencyVersion(version string) (string, bool) {
-	trimmed := strings.TrimSpace(version)
-	if len(trimmed) > 0 && trimmed[len(trimmed)-1] == '*' {
-		return trimmed[:len(trimmed)-1], true
-	}
-	return trimmed, false
-}
+func (patch *Tpp) ExtractResources(ctx context.Context, server Server, observer Observer) error {
+	patch = patch.Effective(conditionsFor(server, patch.version))
 
-func (patch *Tpp) GetDependencies(server Server, recursive ...bool) ([]Patch, error) {
-	recurse := false
-	if len(recursive) > 0 {
-		recurse = recursive[0]
-	}
+	downloadPath := filepath.Join(server.DownloadDir(), patch.version)
 
-	patches := []Patch{}
+	for archive, directory := range patch.Extract {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-	for _, dependencyName := range patch.Dependencies {
-		version, fetchSubDependencies := patch.parseDependencyVersion(dependencyName)
-		if len(version) == 0 {
-			continue
+		if !filepath.IsLocal(archive) {
+			return &PatchError{fmt.Errorf("invalid archive \"%s\": path is nonlocal", archive)}
 		}
 
-		dependency, err := server.GetPatch(version)
-		if err != nil {
-			return []Patch{}, fmt.Errorf("cannot resolve patch dependency \"%s\": %w", version, err)
+		if len(directory) == 0 {
+			directory = "."
 		}
 
-		patches = append(patches, dependency)
+		if !filepath.IsLocal(directory) {
+			return &PatchError{fmt.Errorf("invalid extract directory \"%s\": path is nonlocal", directory)}
+		}
 
-		if dependent, ok := dependency.(Dependent); ok && (fetchSubDependencies || recurse) {
-			subDependencies, err := dependent.GetDependencies(server, recurse)
-			if err != nil {
-				return []Patch{}, fmt.Errorf("cannot resolve recursive dependency \"%s\": %w", version, err)
-			}
-			patches = append(patches, subDependencies...)
+		log.Printf("[EXTRACT] Extracting: %s -> %s", archive, directory)
+
+		err := extractArchive(filepath.Join(downloadPath, archive), filepath.Join(downloadPath, directory))
+		if err != nil {
+			return &PatchError{fmt.Errorf("%s: could not extract \"%s\": %w", patch.version, archive, err)}
 		}
+
+		notify(observer, Event{Kind: DirectiveApplied, Version: patch.version, Directive: "extract", Path: archive})
 	}
 
-	return patches, nil
+	return nil
 }
 
-func (patch *Tpp) updateBoot(server Server) error {
+func (patch *Tpp) DependsOn() []Dependency {
+	dependencies := []Dependency{}
+	for _, dependency := range patch.Dependencies {
+		dependencies = append(dependencies, ParseDependency(dependency))
+	}
+	return dependencies
+}
+
+// Returns the patch's resolved dependencies. See ResolveDependencies.
+func (patch *Tpp) GetDependencies(ctx context.Context, server Server) ([]Patch, error) {
+	return ResolveDependencies(ctx, server, patch)
+}
+
+func (patch *Tpp) updateBoot(server Server, observer Observer) error {
 	if len(patch.Update.Boot) == 0 {
 		return nil
 	}
@@ -162,10 +190,16 @@ func (patch *Tpp) updateBoot(server Server) error {
 		return fmt.Errorf("could not unmarshal boot patch file: %w", err)
 	}
 
-	return server.SetBootConfig(config)
+	err = server.SetBootConfig(config)
+	if err != nil {
+		return err
+	}
+
+	notify(observer, Event{Kind: DirectiveApplied, Version: patch.version, Directive: "boot", Path: patch.Update.Boot})
+	return nil
 }
 
-func (patch *Tpp) updateProtocol(server Server) error {
+func (patch *Tpp) updateProtocol(server Server, observer Observer) error {
 	if len(patch.Update.Protocol) == 0 {
 		return nil
 	}
@@ -173,60 +207,164 @@ func (patch *Tpp) updateProtocol(server Server) error {
 	log.Printf("Updating patch server protocol to \"%s\"", patch.Update.Protocol)
 	server.SetPatchProtocol(patch.Update.Protocol)
 
+	notify(observer, Event{Kind: DirectiveApplied, Version: patch.version, Directive: "protocol", Path: patch.Update.Protocol})
 	return nil
 }
 
-func (patch *Tpp) doUpdates(server Server) error {
+func (patch *Tpp) doUpdates(server Server, observer Observer) error {
 	return errors.Join(
-		patch.updateBoot(server),
-		patch.updateProtocol(server),
+		patch.updateBoot(server, observer),
+		patch.updateProtocol(server, observer),
 	)
 }
 
-func (patch *Tpp) UpdateResources(server Server, rejections *RejectionList) error {
-	dependencies, err := patch.GetDependencies(server)
+func (patch *Tpp) UpdateResources(ctx context.Context, server Server, rejections *RejectionList, downloader *Downloader, observer Observer) error {
+	dependencies, err := patch.GetDependencies(ctx, server)
+	if err != nil {
+		return &PatchError{err}
+	}
+
+	_, err = FindConflicts(server, append(dependencies, patch))
 	if err != nil {
 		return &PatchError{err}
 	}
 
+	jobs := []DownloadJob{}
+	extractables := []Extractable{}
+	collectJobs := func(p Patch) error {
+		downloadable, ok := p.(Downloadable)
+		if !ok {
+			return p.DownloadResources(ctx, server, rejections, downloader, observer)
+		}
+
+		patchJobs, err := downloadable.DownloadJobs(server, rejections)
+		if err != nil {
+			return err
+		}
+
+		jobs = append(jobs, patchJobs...)
+
+		if extractable, ok := p.(Extractable); ok {
+			extractables = append(extractables, extractable)
+		}
+
+		return nil
+	}
+
 	for _, dependency := range dependencies {
-		err := dependency.DownloadResources(server, rejections)
+		notify(observer, Event{Kind: DependencyEntered, Version: dependency.Version()})
+
+		err := collectJobs(dependency)
 		if err != nil {
 			return &PatchError{fmt.Errorf("dependency \"%s\": %w", dependency.Version(), err)}
 		}
 	}
 
-	err = patch.DownloadResources(server, rejections)
+	err = collectJobs(patch)
 	if err != nil {
 		return &PatchError{err}
 	}
 
-	return patch.doUpdates(server)
-}
-
-func (patch *Tpp) replace(source, destination string) error {
-	sourceFile, err := os.Open(source)
+	log.Println("Starting downloads...")
+	err = downloader.Download(ctx, server, jobs, observer).Err()
 	if err != nil {
-		return fmt.Errorf("could not open patch source: %w", err)
+		return err
 	}
-	defer sourceFile.Close()
 
-	destinationFile, err := os.OpenFile(destination, os.O_WRONLY|os.O_TRUNC, 0755)
-	if err != nil {
-		return fmt.Errorf("could not open patch destination: %w", err)
+	for _, extractable := range extractables {
+		err := extractable.ExtractResources(ctx, server, observer)
+		if err != nil {
+			return err
+		}
 	}
-	defer destinationFile.Close()
 
-	_, err = io.Copy(destinationFile, sourceFile)
-	if err != nil {
-		return fmt.Errorf("could not copy \"%s\" to \"%s\": %w", source, destination, err)
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	return nil
+	return patch.doUpdates(server, observer)
 }
 
-func (patch *Tpp) replaceResources(clientDirectory string, resources client.Resources, server Server) error {
+// Stages the patch's transfers in the order: replace, delta, add, remove.
+func (patch *Tpp) StageResources(ctx context.Context, tx *Transaction, server Server) error {
+	patch = patch.Effective(conditionsFor(server, patch.version))
+
+	downloadPath := filepath.Join(server.DownloadDir(), patch.version)
+
 	for source, destination := range patch.Replace {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !filepath.IsLocal(source) {
+			return fmt.Errorf("invalid source resource \"%s\": path is nonlocal", source)
+		}
+
+		if !filepath.IsLocal(destination) {
+			return fmt.Errorf("invalid destination resource \"%s\": path is nonlocal", destination)
+		}
+
+		log.Printf("[REPLACE] Staging: %s -> %s", source, destination)
+
+		err := tx.StageReplace(patch.version, filepath.Clean(destination), filepath.Join(downloadPath, source))
+		if err != nil {
+			return err
+		}
+	}
+
+	for source, entry := range patch.Delta {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !filepath.IsLocal(source) {
+			return fmt.Errorf("invalid source resource \"%s\": path is nonlocal", source)
+		}
+
+		if !filepath.IsLocal(entry.Path) {
+			return fmt.Errorf("invalid destination resource \"%s\": path is nonlocal", entry.Path)
+		}
+
+		if len(entry.SHA256) == 0 {
+			return fmt.Errorf("invalid delta \"%s\": missing sha256", source)
+		}
+
+		log.Printf("[DELTA] Staging: %s -> %s", source, entry.Path)
+
+		resourceName := filepath.Clean(entry.Path)
+		pristine, err := tx.Pristine(resourceName)
+		if err != nil {
+			return err
+		}
+
+		d, err := os.ReadFile(filepath.Join(downloadPath, source))
+		if err != nil {
+			return fmt.Errorf("could not read delta: %w", err)
+		}
+
+		data, err := delta.Patch(pristine, d)
+		if err != nil {
+			return fmt.Errorf("could not apply \"%s\" to \"%s\": %w", source, resourceName, err)
+		}
+
+		checksum := newChecksum()
+		checksum.Write(data)
+		err = DownloadEntry{SHA256: entry.SHA256}.Verify(int64(len(data)), checksum)
+		if err != nil {
+			return fmt.Errorf("could not apply \"%s\" to \"%s\": %w", source, resourceName, err)
+		}
+
+		err = tx.StageData(patch.version, "delta", resourceName, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	for source, destination := range patch.Add {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if !filepath.IsLocal(source) {
 			return fmt.Errorf("invalid source resource \"%s\": path is nonlocal", source)
 		}
@@ -235,26 +373,35 @@ func (patch *Tpp) replaceResources(clientDirectory string, resources client.Reso
 			return fmt.Errorf("invalid destination resource \"%s\": path is nonlocal", destination)
 		}
 
-		log.Printf("[REPLACE] Transferring: %s -> %s", source, destination)
+		log.Printf("[ADD] Staging: %s -> %s", source, destination)
 
 		resourceName := filepath.Clean(destination)
-		if !resources.Replacements().Has(resourceName) {
-			resource, err := client.ReadResource(clientDirectory, resourceName)
-			if err != nil {
-				return fmt.Errorf("could not read patch destination: %w", err)
-			}
 
-			log.Printf("Adding %s to replacements cache", resource.Path)
-			err = resources.Replacements().Add(resource)
-			if err != nil {
-				return fmt.Errorf("could not add patch destination to replacements cache: %w", err)
-			}
+		// Resources added by an overridden version are replaced instead
+		var err error
+		if version, ok := tx.ChangedBy(resourceName); ok && tx.Exists(resourceName) && patch.Overrides(version) {
+			err = tx.StageReplace(patch.version, resourceName, filepath.Join(downloadPath, source))
+		} else {
+			err = tx.StageAdd(patch.version, resourceName, filepath.Join(downloadPath, source))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, resource := range patch.Remove {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !filepath.IsLocal(resource) {
+			return fmt.Errorf("invalid resource \"%s\": path is nonlocal", resource)
 		}
 
-		sourcePath := filepath.Join(server.DownloadDir(), patch.version, source)
-		destinationPath := filepath.Join(clientDirectory, resourceName)
+		log.Printf("[REMOVE] Staging: %s", resource)
 
-		err := patch.replace(sourcePath, destinationPath)
+		err := tx.StageRemove(patch.version, filepath.Clean(resource))
 		if err != nil {
 			return err
 		}
@@ -263,85 +410,179 @@ func (patch *Tpp) replaceResources(clientDirectory string, resources client.Reso
 	return nil
 }
 
-func (patch *Tpp) add(source, destination string) error {
-	sourceFile, err := os.Open(source)
+// Stages and then commits the patch's transfers. If any transfer fails, none of the patch's changes
+// remain in the clientDirectory. See Transaction.
+func (patch *Tpp) TransferResources(ctx context.Context, clientDirectory string, resources client.Resources, server Server, observer Observer) error {
+	return transferAll(ctx, clientDirectory, resources, server, observer, nil, patch)
+}
+
+// Same as TransferResources, but the transfers of the patch's dependencies are staged first, within the
+// same transaction.
+func (patch *Tpp) TransferResourcesWithDependencies(ctx context.Context, clientDirectory string, resources client.Resources, server Server, observer Observer) error {
+	dependencies, err := patch.GetDependencies(ctx, server)
 	if err != nil {
-		return fmt.Errorf("could not open patch source: %w", err)
+		return err
 	}
-	defer sourceFile.Close()
 
-	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_TRUNC, 0755)
+	return transferAll(ctx, clientDirectory, resources, server, observer, dependencies, patch)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Reads and unmarshals the boot.cfg located by path within the Remote Patch Directory.
+func readBootConfig(read ResourceReader, path string) (*ldf.BootConfig, error) {
+	data, err := read(path)
 	if err != nil {
-		return fmt.Errorf("could not open patch destination: %w", err)
+		return nil, fmt.Errorf("could not get boot patch file: %w", err)
 	}
-	defer destinationFile.Close()
 
-	_, err = io.Copy(destinationFile, sourceFile)
+	config := &ldf.BootConfig{}
+	err = ldf.Unmarshal(data, config)
 	if err != nil {
-		return fmt.Errorf("could not copy \"%s\" to \"%s\": %w", source, destination, err)
+		return nil, fmt.Errorf("could not unmarshal boot patch file: %w", err)
 	}
 
-	return nil
+	return config, nil
 }
 
-func (patch *Tpp) addResources(clientDirectory string, resources client.Resources, server Server) error {
-	for source, destination := range patch.Add {
-		if !filepath.IsLocal(source) {
-			return fmt.Errorf("invalid source resource \"%s\": path is nonlocal", source)
-		}
+// Reads the patch's boot.cfg from the server without saving it. If the boot.cfg is not one of the patch's
+// downloads, e.g., it is unpacked from an archive, nil is returned.
+func (patch *Tpp) remoteBootConfig(ctx context.Context, server Server) (*ldf.BootConfig, error) {
+	patch = patch.Effective(conditionsFor(server, patch.version))
 
-		if !filepath.IsLocal(destination) {
-			return fmt.Errorf("invalid destination resource \"%s\": path is nonlocal", destination)
+	for _, path := range sortedKeys(patch.Download) {
+		name, err := downloadName(path, patch.Download[path])
+		if err != nil || name != filepath.Clean(patch.Update.Boot) {
+			continue
 		}
 
-		log.Printf("[ADD] Transferring: %s -> %s", source, destination)
+		return readBootConfig(RemoteReader(ctx, server), path)
+	}
 
-		if client.Contains(clientDirectory, destination) {
-			return fmt.Errorf("cannot transfer \"%s\" to \"%s\": resource already exists", source, destination)
+	return nil, nil
+}
+
+// Adds the patch's downloads and transfers to the plan. The patch's updates are only added if the patch
+// is the planned patch, since the updates of dependencies are never applied.
+func (patch *Tpp) PlanResources(ctx context.Context, server Server, plan *Plan) error {
+	plan.Conditionals += len(patch.When)
+	conditions := plan.conditionsFor(patch.version)
+	plan.AppliedConditionals += patch.applicable(conditions)
+	patch = patch.Effective(conditions)
+
+	if err := ValidateVersionName(patch.version); err != nil {
+		return err
+	}
+
+	for _, path := range sortedKeys(patch.Download) {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		resourceName := filepath.Clean(destination)
-		if !resources.Additions().Has(resourceName) {
-			log.Printf("Adding %s to additions cache", resourceName)
-			err := resources.Additions().Add(resourceName)
-			if err != nil {
-				return fmt.Errorf("could not add patch destination to additions cache: %w", err)
-			}
+		err := plan.addDownload(patch.version, path, patch.Download[path])
+		if err != nil {
+			return err
 		}
+	}
 
-		sourcePath := filepath.Join(server.DownloadDir(), patch.version, source)
-		destinationPath := filepath.Join(clientDirectory, destination)
+	for _, archive := range sortedKeys(patch.Extract) {
+		plan.addExtraction(patch.version, archive, patch.Extract[archive])
+	}
 
-		err := patch.add(sourcePath, destinationPath)
+	if err := patch.PlanTransfers(plan); err != nil {
+		return err
+	}
+
+	if patch.version != plan.Version {
+		return nil
+	}
+
+	plan.Protocol = patch.Update.Protocol
+
+	if len(patch.Update.Boot) > 0 {
+		plan.BootFile = patch.Update.Boot
+
+		config, err := patch.remoteBootConfig(ctx, server)
 		if err != nil {
 			return err
 		}
+
+		if config != nil {
+			plan.BootChanges, err = diffBootConfigs(server.BootConfig(), config)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
-func (patch *Tpp) TransferResources(clientDirectory string, resources client.Resources, server Server) error {
-	return errors.Join(
-		patch.replaceResources(clientDirectory, resources, server),
-		patch.addResources(clientDirectory, resources, server),
-	)
+func (patch *Tpp) UnhashedDownloads() []string {
+	unhashed := []string{}
+
+	for _, directives := range append([]Directives{patch.Directives}, patch.conditionalDirectives()...) {
+		for _, path := range sortedKeys(directives.Download) {
+			if !directives.Download[path].HasChecksum() {
+				unhashed = append(unhashed, path)
+			}
+		}
+	}
+
+	return unhashed
 }
 
-func (patch *Tpp) TransferResourcesWithDependencies(clientDirectory string, resources client.Resources, server Server) error {
-	dependencies, err := patch.GetDependencies(server)
-	if err != nil {
-		return err
+// Adds the patch's transfers to the plan, in the order they are staged.
+func (patch *Tpp) PlanTransfers(plan *Plan) error {
+	patch = patch.Effective(plan.conditionsFor(patch.version))
+
+	checkLocal := func(source, destination string) error {
+		if len(source) > 0 && !filepath.IsLocal(source) {
+			return fmt.Errorf("invalid source resource \"%s\": path is nonlocal", source)
+		}
+
+		if !filepath.IsLocal(destination) {
+			return fmt.Errorf("invalid destination resource \"%s\": path is nonlocal", destination)
+		}
+
+		return nil
 	}
 
-	for _, dependency := range dependencies {
-		err := dependency.TransferResources(clientDirectory, resources, server)
-		if err != nil {
-			return fmt.Errorf("transfer dependecy: %w", err)
+	for _, source := range sortedKeys(patch.Replace) {
+		if err := checkLocal(source, patch.Replace[source]); err != nil {
+			return err
+		}
+		plan.addTransfer(patch.version, "replace", source, filepath.Clean(patch.Replace[source]))
+	}
+
+	for _, source := range sortedKeys(patch.Delta) {
+		if err := checkLocal(source, patch.Delta[source].Path); err != nil {
+			return err
 		}
+		plan.addTransfer(patch.version, "delta", source, filepath.Clean(patch.Delta[source].Path))
 	}
 
-	return patch.TransferResources(clientDirectory, resources, server)
+	for _, source := range sortedKeys(patch.Add) {
+		if err := checkLocal(source, patch.Add[source]); err != nil {
+			return err
+		}
+		plan.addTransfer(patch.version, "add", source, filepath.Clean(patch.Add[source]))
+	}
+
+	for _, resource := range patch.Remove {
+		if err := checkLocal("", resource); err != nil {
+			return err
+		}
+		plan.addTransfer(patch.version, "remove", "", filepath.Clean(resource))
+	}
+	return nil
 }
 
 func (patch *Tpp) Summary() string {
@@ -355,5 +596,10 @@ func (patch *Tpp) Summary() string {
 		updates++
 	}
 
-	return fmt.Sprintf("%d download(s); %d update(s); %d replacement(s); %d addition(s)", len(patch.Download), updates, len(patch.Replace), len(patch.Add))
+	summary := fmt.Sprintf("%d download(s); %d extraction(s); %d update(s); %d replacement(s); %d delta(s); %d addition(s); %d removal(s)", len(patch.Download), len(patch.Extract), updates, len(patch.Replace), len(patch.Delta), len(patch.Add), len(patch.Remove))
+	if len(patch.When) > 0 {
+		summary = fmt.Sprintf("%s; %d conditional block(s)", summary, len(patch.When))
+	}
+
+	return summary
 }
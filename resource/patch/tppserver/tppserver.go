@@ -0,0 +1,306 @@
+// Package tppserver implements a patch server which serves a Server Patch Directory using the
+// TPP protocol described by PATCHING.md.
+//
+// The server can be run with the launcher's serve subcommand:
+//
+//	nimbus-launcher serve -dir patches -addr :3000
+package tppserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
+)
+
+// The header which clients send their patch token with.
+const patchTokenHeader = server.HEADER_PATCH_TOKEN
+
+type Config struct {
+	// The Server Patch Directory, which contains each of the version directories.
+	FS fs.FS
+
+	// The URL path which the Server Patch Directory is served from, i.e. the PATCHSERVERDIR of
+	// the servers' boot.cfg. Defaults to "/patches".
+	Prefix string
+
+	// If not empty, every request must include a TPP-Token header with one of the tokens.
+	Tokens []string
+
+	// The current version of the generated summary.json. If empty, the greatest available version
+	// is used. See patch.CompareVersions
+	//
+	// If not empty, the version must be valid and one of the available versions, otherwise the summary.json
+	// cannot be generated, and Server.Summary returns an error.
+	CurrentVersion string
+
+	// The runner and mirrors of the generated summary.json.
+	Runner  string
+	Mirrors []string
+}
+
+// An http.Handler which serves the files within a Server Patch Directory.
+//
+// If the directory does not contain a summary.json, the summary.json is generated from the version
+// directories which contain a patch.json, along with the metadata within each patch.json. Directories
+// with invalid version names are left out of the generated summary.json, but their files are still served.
+type Server struct {
+	config Config
+}
+
+func New(config Config) *Server {
+	if len(config.Prefix) == 0 {
+		config.Prefix = "/patches"
+	}
+
+	config.Prefix = path.Clean("/" + config.Prefix)
+
+	return &Server{
+		config: config,
+	}
+}
+
+// Returns the summary.json served by the server: either the summary.json within the Server Patch
+// Directory, or the generated summary.
+func (server *Server) Summary() (patch.Summary, error) {
+	data, _, err := server.summary()
+	if err != nil {
+		return patch.Summary{}, err
+	}
+
+	summary := patch.Summary{}
+	err = json.Unmarshal(data, &summary)
+	if err != nil {
+		return patch.Summary{}, fmt.Errorf("cannot unmarshal summary.json: %w", err)
+	}
+
+	return summary, nil
+}
+
+// Returns the metadata within the version's patch.json, if the patch.json includes any metadata.
+func (server *Server) metadata(version string) (patch.Metadata, bool) {
+	data, err := fs.ReadFile(server.config.FS, path.Join(version, "patch.json"))
+	if err != nil {
+		return patch.Metadata{}, false
+	}
+
+	document := struct {
+		Metadata *patch.Metadata `json:"metadata"`
+	}{}
+
+	if err := json.Unmarshal(data, &document); err != nil || document.Metadata == nil {
+		return patch.Metadata{}, false
+	}
+
+	return *document.Metadata, true
+}
+
+// Returns the data of the summary.json, and the time it was last modified. The generated summary.json
+// depends on the server's configuration as well as the version directories, so it has no modification
+// time, and is instead validated by its ETag.
+func (server *Server) summary() ([]byte, time.Time, error) {
+	data, err := fs.ReadFile(server.config.FS, "summary.json")
+	if err == nil {
+		modTime := time.Time{}
+		if info, err := fs.Stat(server.config.FS, "summary.json"); err == nil {
+			modTime = info.ModTime()
+		}
+
+		return data, modTime, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, fmt.Errorf("cannot read summary.json: %w", err)
+	}
+
+	if len(server.config.CurrentVersion) > 0 {
+		if err := patch.ValidateVersionName(server.config.CurrentVersion); err != nil {
+			return nil, time.Time{}, fmt.Errorf("invalid current version: %w", err)
+		}
+	}
+
+	entries, err := fs.ReadDir(server.config.FS, ".")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("cannot read patch directory: %w", err)
+	}
+
+	summary := patch.Summary{
+		Runner:            server.config.Runner,
+		CurrentVersion:    server.config.CurrentVersion,
+		AvailableVersions: []string{},
+		Mirrors:           server.config.Mirrors,
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		if _, err := fs.Stat(server.config.FS, path.Join(entry.Name(), "patch.json")); err != nil {
+			continue
+		}
+
+		if err := patch.ValidateVersionName(entry.Name()); err != nil {
+			log.Printf("Skipping version directory: %v", err)
+			continue
+		}
+
+		summary.AvailableVersions = append(summary.AvailableVersions, entry.Name())
+
+		if metadata, ok := server.metadata(entry.Name()); ok {
+			if summary.Versions == nil {
+				summary.Versions = make(map[string]patch.Metadata)
+			}
+			summary.Versions[entry.Name()] = metadata
+		}
+	}
+
+	sort.Slice(summary.AvailableVersions, func(i, j int) bool {
+		return patch.CompareVersions(summary.AvailableVersions[i], summary.AvailableVersions[j]) < 0
+	})
+
+	if len(summary.CurrentVersion) == 0 && len(summary.AvailableVersions) > 0 {
+		summary.CurrentVersion = summary.AvailableVersions[len(summary.AvailableVersions)-1]
+	}
+
+	available := len(summary.CurrentVersion) == 0
+	for _, version := range summary.AvailableVersions {
+		available = available || version == summary.CurrentVersion
+	}
+
+	if !available {
+		return nil, time.Time{}, fmt.Errorf("current version \"%s\" is not an available version", summary.CurrentVersion)
+	}
+
+	data, err = json.Marshal(summary)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return data, time.Time{}, nil
+}
+
+// Returns true if the request does not need a token, or if the request's TPP-Token is one of the tokens.
+func (server *Server) authorized(request *http.Request) bool {
+	if len(server.config.Tokens) == 0 {
+		return true
+	}
+
+	token := request.Header.Get(patchTokenHeader)
+	for _, expected := range server.config.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (server *Server) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	file, err := server.config.FS.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		log.Printf("Could not open \"%s\": %v", name, err)
+		http.Error(w, "could not open file", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			log.Printf("Could not read \"%s\": %v", name, err)
+			http.Error(w, "could not read file", http.StatusInternalServerError)
+			return
+		}
+
+		content = bytes.NewReader(data)
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !server.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefix := strings.TrimSuffix(server.config.Prefix, "/") + "/"
+	name, ok := strings.CutPrefix(path.Clean(r.URL.Path), prefix)
+
+	if !ok || len(name) == 0 || !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if name == "summary.json" {
+		data, modTime, err := server.summary()
+		if err != nil {
+			log.Printf("Could not create summary.json: %v", err)
+			http.Error(w, "could not create summary.json", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if modTime.IsZero() {
+			w.Header().Set("ETag", fmt.Sprintf("\"%x\"", sha256.Sum256(data)))
+		}
+		http.ServeContent(w, r, name, modTime, bytes.NewReader(data))
+		return
+	}
+
+	server.serveFile(w, r, name)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// Returns a handler which logs each request along with its response status and duration.
+func Logged(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		handler.ServeHTTP(recorder, r)
+
+		log.Printf("%s %s %s -> %d (%v)", r.RemoteAddr, r.Method, r.URL.Path, recorder.status, time.Since(start))
+	})
+}
@@ -0,0 +1,204 @@
+package tppserver_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch/tppserver"
+)
+
+func patchDirectory() fstest.MapFS {
+	return fstest.MapFS{
+		"v1.0.0/patch.json":          {Data: []byte(`{}`)},
+		"v10.0.0/patch.json":         {Data: []byte(`{}`)},
+		"v2.0.0/patch.json":          {Data: []byte(`{"metadata":{"title":"Second","mandatory":true}}`)},
+		"v2.0.0-beta/patch.json":     {Data: []byte(`{}`)},
+		"v3.0.0/resource":            {Data: []byte("no patch.json")},
+		"invalid_version/patch.json": {Data: []byte(`{}`)},
+		"common/a":                   {Data: []byte("Test 1")},
+	}
+}
+
+func request(t *testing.T, handler http.Handler, method, path, token string) (int, []byte) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, nil)
+	if len(token) > 0 {
+		r.Header.Set("TPP-Token", token)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	data, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w.Code, data
+}
+
+func TestGeneratedSummary(t *testing.T) {
+	server := tppserver.New(tppserver.Config{
+		FS:      patchDirectory(),
+		Mirrors: []string{"https://mirror.example.com/patches"},
+	})
+
+	status, data := request(t, server, http.MethodGet, "/patches/summary.json", "")
+	if status != http.StatusOK {
+		t.Fatalf("test generated summary: expected status 200 but got %d", status)
+	}
+
+	summary := patch.Summary{}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("test generated summary: %v", err)
+	}
+
+	expectedVersions := "v1.0.0 v2.0.0-beta v2.0.0 v10.0.0"
+	if versions := strings.Join(summary.AvailableVersions, " "); versions != expectedVersions {
+		t.Errorf("test generated summary: expected versions \"%s\" but got \"%s\"", expectedVersions, versions)
+	}
+
+	if summary.CurrentVersion != "v10.0.0" {
+		t.Errorf("test generated summary: expected current version \"v10.0.0\" but got \"%s\"", summary.CurrentVersion)
+	}
+
+	if metadata := summary.Versions["v2.0.0"]; metadata.Title != "Second" || !metadata.Mandatory || len(summary.Versions) != 1 {
+		t.Errorf("test generated summary: expected the metadata of v2.0.0 but got %v", summary.Versions)
+	}
+
+	if len(summary.Mirrors) != 1 {
+		t.Errorf("test generated summary: expected 1 mirror but got %d", len(summary.Mirrors))
+	}
+
+	// Invalid versions are not advertised, but are still served
+	status, _ = request(t, server, http.MethodGet, "/patches/invalid_version/patch.json", "")
+	if status != http.StatusOK {
+		t.Errorf("test generated summary: invalid_version: expected status 200 but got %d", status)
+	}
+
+	pinned := tppserver.New(tppserver.Config{
+		FS:             patchDirectory(),
+		CurrentVersion: "v2.0.0",
+	})
+
+	summary, err := pinned.Summary()
+	if err != nil {
+		t.Fatalf("test generated summary: %v", err)
+	}
+
+	if summary.CurrentVersion != "v2.0.0" {
+		t.Errorf("test generated summary: expected current version \"v2.0.0\" but got \"%s\"", summary.CurrentVersion)
+	}
+}
+
+func TestInvalidCurrentVersion(t *testing.T) {
+	for _, current := range []string{"v3.0.0", "current"} {
+		server := tppserver.New(tppserver.Config{FS: patchDirectory(), CurrentVersion: current})
+
+		if _, err := server.Summary(); err == nil {
+			t.Errorf("test invalid current version: %s: expected an error", current)
+		}
+
+		if status, _ := request(t, server, http.MethodGet, "/patches/summary.json", ""); status != http.StatusInternalServerError {
+			t.Errorf("test invalid current version: %s: expected status 500 but got %d", current, status)
+		}
+	}
+}
+
+func TestServedSummary(t *testing.T) {
+	directory := patchDirectory()
+	directory["summary.json"] = &fstest.MapFile{Data: []byte(`{"currentVersion":"v1.0.0","availableVersions":["v1.0.0"]}`)}
+
+	server := tppserver.New(tppserver.Config{FS: directory})
+
+	_, data := request(t, server, http.MethodGet, "/patches/summary.json", "")
+	if string(data) != string(directory["summary.json"].Data) {
+		t.Errorf("test served summary: expected the saved summary.json but got \"%s\"", data)
+	}
+}
+
+func TestServerRequests(t *testing.T) {
+	server := tppserver.New(tppserver.Config{
+		FS:     patchDirectory(),
+		Prefix: "files",
+		Tokens: []string{"secret", "other"},
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		expected int
+	}{
+		{"resource", http.MethodGet, "/files/common/a", "secret", http.StatusOK},
+		{"second token", http.MethodHead, "/files/common/a", "other", http.StatusOK},
+		{"missing token", http.MethodGet, "/files/common/a", "", http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "/files/common/a", "wrong", http.StatusUnauthorized},
+		{"method", http.MethodPost, "/files/common/a", "secret", http.StatusMethodNotAllowed},
+		{"missing", http.MethodGet, "/files/common/b", "secret", http.StatusNotFound},
+		{"directory", http.MethodGet, "/files/common", "secret", http.StatusNotFound},
+		{"outside prefix", http.MethodGet, "/filesystem/common/a", "secret", http.StatusNotFound},
+		{"traversal", http.MethodGet, "/files/../common/a", "secret", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		status, _ := request(t, server, test.method, test.path, test.token)
+		if status != test.expected {
+			t.Errorf("test server requests: %s: expected status %d but got %d", test.name, test.expected, status)
+		}
+	}
+}
+
+func TestGeneratedSummaryValidation(t *testing.T) {
+	directory := patchDirectory()
+
+	get := func(server http.Handler, header http.Header) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, "/patches/summary.json", nil)
+		for key, values := range header {
+			r.Header[key] = values
+		}
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	response := get(tppserver.New(tppserver.Config{FS: directory, CurrentVersion: "v1.0.0"}), nil)
+	etag := response.Header.Get("ETag")
+	if len(etag) == 0 {
+		t.Fatal("test generated summary validation: expected an ETag")
+	}
+
+	// Validators saved by the client are sent with each request, as in server.Server.GetPatchesSummary
+	validators := http.Header{}
+	validators.Set("If-None-Match", etag)
+	validators.Set("If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+
+	response = get(tppserver.New(tppserver.Config{FS: directory, CurrentVersion: "v1.0.0"}), validators)
+	if response.StatusCode != http.StatusNotModified {
+		t.Errorf("test generated summary validation: unchanged: expected status 304 but got %d", response.StatusCode)
+	}
+
+	response = get(tppserver.New(tppserver.Config{FS: directory, CurrentVersion: "v2.0.0"}), validators)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("test generated summary validation: changed current version: expected status 200 but got %d", response.StatusCode)
+	}
+
+	summary := patch.Summary{}
+	if err := json.NewDecoder(response.Body).Decode(&summary); err != nil {
+		t.Fatalf("test generated summary validation: %v", err)
+	}
+
+	if summary.CurrentVersion != "v2.0.0" {
+		t.Errorf("test generated summary validation: expected current version \"v2.0.0\" but got \"%s\"", summary.CurrentVersion)
+	}
+}
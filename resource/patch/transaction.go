@@ -0,0 +1,374 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/I-Am-Dench/nimbus-launcher/client"
+)
+
+// A single change to a client resource which has been staged by a transaction.
+type stagedTransfer struct {
+	version   string
+	directive string
+
+	// The client resource, relative to the client directory.
+	path string
+
+	// The file which is copied to the client resource, or empty for removals.
+	source string
+}
+
+// Stages the changes made by one or more patches, so that every change can be checked before
+// the client directory is modified, and then commits the changes.
+//
+// While the changes are committed, each changed resource is recorded in the journal of the
+// client.Resources. If any change fails, every recorded resource is rolled back to its cached
+// state. If the launcher exits before the transaction finishes, the resources are rolled back
+// by client.Rollback the next time the launcher starts.
+type Transaction struct {
+	clientDirectory string
+	resources       client.Resources
+
+	// The directory where resources produced while staging, e.g. patched deltas, are written to.
+	stageDir string
+
+	transfers []stagedTransfer
+
+	// The planned existence of client resources after the staged transfers.
+	exists map[string]bool
+
+	// The version which staged the last transfer of each client resource.
+	changedBy map[string]string
+}
+
+// Patches which implement Stager can stage their transfers within a Transaction.
+type Stager interface {
+	StageResources(ctx context.Context, tx *Transaction, server Server) error
+}
+
+func newTransaction(clientDirectory string, resources client.Resources, server Server) (*Transaction, error) {
+	err := os.MkdirAll(server.DownloadDir(), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("could not create staging directory: %w", err)
+	}
+
+	stageDir, err := os.MkdirTemp(server.DownloadDir(), ".staging*")
+	if err != nil {
+		return nil, fmt.Errorf("could not create staging directory: %w", err)
+	}
+
+	return &Transaction{
+		clientDirectory: clientDirectory,
+		resources:       resources,
+		stageDir:        stageDir,
+		exists:          make(map[string]bool),
+		changedBy:       make(map[string]string),
+	}, nil
+}
+
+// Returns true if the client resource exists after the transfers which have already been staged.
+func (tx *Transaction) Exists(resourceName string) bool {
+	exists, ok := tx.exists[resourceName]
+	if !ok {
+		return client.Contains(tx.clientDirectory, resourceName)
+	}
+
+	return exists
+}
+
+// Returns an error if the client resource exists, and was not created by a staged transfer, but is a directory.
+func (tx *Transaction) checkNotDirectory(resourceName string) error {
+	if _, ok := tx.exists[resourceName]; ok {
+		return nil
+	}
+
+	stat, err := os.Stat(filepath.Join(tx.clientDirectory, resourceName))
+	if err == nil && stat.IsDir() {
+		return fmt.Errorf("cannot transfer \"%s\": resource is a directory", resourceName)
+	}
+
+	return nil
+}
+
+func (tx *Transaction) stage(version, directive, resourceName, source string) {
+	tx.transfers = append(tx.transfers, stagedTransfer{
+		version:   version,
+		directive: directive,
+		path:      resourceName,
+		source:    source,
+	})
+
+	tx.exists[resourceName] = directive != "remove"
+	tx.changedBy[resourceName] = version
+}
+
+// Returns the version which staged the last transfer of the client resource, if any.
+func (tx *Transaction) ChangedBy(resourceName string) (string, bool) {
+	version, ok := tx.changedBy[resourceName]
+	return version, ok
+}
+
+// Stages the replacement of an existing client resource with the source file.
+func (tx *Transaction) StageReplace(version, resourceName, source string) error {
+	if !tx.Exists(resourceName) {
+		return fmt.Errorf("cannot replace \"%s\": resource does not exist", resourceName)
+	}
+
+	if err := tx.checkNotDirectory(resourceName); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(source); err != nil {
+		return fmt.Errorf("could not open patch source: %w", err)
+	}
+
+	tx.stage(version, "replace", resourceName, source)
+	return nil
+}
+
+// Stages the addition of a client resource which does not already exist.
+func (tx *Transaction) StageAdd(version, resourceName, source string) error {
+	if tx.Exists(resourceName) {
+		return fmt.Errorf("cannot transfer \"%s\" to \"%s\": resource already exists", source, resourceName)
+	}
+
+	if _, err := os.Stat(source); err != nil {
+		return fmt.Errorf("could not open patch source: %w", err)
+	}
+
+	tx.stage(version, "add", resourceName, source)
+	return nil
+}
+
+// Stages the replacement of an existing client resource with data. The data is written to
+// the staging directory until the transaction is committed.
+func (tx *Transaction) StageData(version, directive, resourceName string, data []byte) error {
+	if !tx.Exists(resourceName) {
+		return fmt.Errorf("cannot replace \"%s\": resource does not exist", resourceName)
+	}
+
+	if err := tx.checkNotDirectory(resourceName); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(tx.stageDir, "resource*")
+	if err != nil {
+		return fmt.Errorf("could not stage \"%s\": %w", resourceName, err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	if err != nil {
+		return fmt.Errorf("could not stage \"%s\": %w", resourceName, err)
+	}
+
+	tx.stage(version, directive, resourceName, file.Name())
+	return nil
+}
+
+// Stages the removal of a client resource. Resources which do not exist are skipped.
+func (tx *Transaction) StageRemove(version, resourceName string) error {
+	if !tx.Exists(resourceName) {
+		log.Printf("\"%s\" does not exist; Skipping removal", resourceName)
+		return nil
+	}
+
+	if err := tx.checkNotDirectory(resourceName); err != nil {
+		return err
+	}
+
+	tx.stage(version, "remove", resourceName, "")
+	return nil
+}
+
+// Returns the unpatched contents of the client resource, either from the replacements cache,
+// or from the client directory if the resource has not been cached.
+func (tx *Transaction) Pristine(resourceName string) ([]byte, error) {
+	if tx.resources.Replacements().Has(resourceName) {
+		resource, err := tx.resources.Replacements().Get(resourceName)
+		if err != nil {
+			return nil, fmt.Errorf("could not read cached resource: %w", err)
+		}
+
+		return resource.Data, nil
+	}
+
+	resource, err := client.ReadResource(tx.clientDirectory, resourceName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read patch destination: %w", err)
+	}
+
+	return resource.Data, nil
+}
+
+// Caches the unpatched resource before it is changed for the first time, so that the resource
+// can be restored.
+func (tx *Transaction) cache(transfer stagedTransfer) error {
+	replacements := tx.resources.Replacements()
+	additions := tx.resources.Additions()
+
+	if transfer.directive == "add" {
+		if additions.Has(transfer.path) {
+			return nil
+		}
+
+		log.Printf("Adding %s to additions cache", transfer.path)
+		err := additions.Add(transfer.path)
+		if err != nil {
+			return fmt.Errorf("could not add patch destination to additions cache: %w", err)
+		}
+
+		return nil
+	}
+
+	// Resources added by a patch are already deleted when the client resources are restored
+	if replacements.Has(transfer.path) || additions.Has(transfer.path) {
+		return nil
+	}
+
+	resource, err := client.ReadResource(tx.clientDirectory, transfer.path)
+	if err != nil {
+		return fmt.Errorf("could not read patch destination: %w", err)
+	}
+
+	log.Printf("Adding %s to replacements cache", resource.Path)
+	err = replacements.Add(resource)
+	if err != nil {
+		return fmt.Errorf("could not add patch destination to replacements cache: %w", err)
+	}
+
+	return nil
+}
+
+func copyResource(source, destination string, flags int) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("could not open patch source: %w", err)
+	}
+	defer sourceFile.Close()
+
+	destinationFile, err := os.OpenFile(destination, flags, 0755)
+	if err != nil {
+		return fmt.Errorf("could not open patch destination: %w", err)
+	}
+	defer destinationFile.Close()
+
+	_, err = io.Copy(destinationFile, sourceFile)
+	if err != nil {
+		return fmt.Errorf("could not copy \"%s\" to \"%s\": %w", source, destination, err)
+	}
+
+	return nil
+}
+
+func (tx *Transaction) apply(transfer stagedTransfer) error {
+	destination := filepath.Join(tx.clientDirectory, transfer.path)
+
+	switch transfer.directive {
+	case "add":
+		// Added resources may be within directories which do not exist yet
+		err := os.MkdirAll(filepath.Dir(destination), 0755)
+		if err != nil {
+			return fmt.Errorf("could not create directory for \"%s\": %w", transfer.path, err)
+		}
+
+		return copyResource(transfer.source, destination, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_TRUNC)
+	case "remove":
+		return client.RemoveResource(tx.clientDirectory, transfer.path)
+	default:
+		return copyResource(transfer.source, destination, os.O_WRONLY|os.O_TRUNC)
+	}
+}
+
+func (tx *Transaction) commitTransfer(transfer stagedTransfer) error {
+	journal := tx.resources.Journal()
+	if !journal.Has(transfer.path) {
+		err := journal.Add(transfer.path)
+		if err != nil {
+			return fmt.Errorf("could not write transfer journal: %w", err)
+		}
+	}
+
+	err := tx.cache(transfer)
+	if err != nil {
+		return err
+	}
+
+	return tx.apply(transfer)
+}
+
+// Commits every staged transfer to the client directory. If any transfer fails, or ctx is done,
+// every changed resource is rolled back.
+func (tx *Transaction) Commit(ctx context.Context, observer Observer) error {
+	for _, transfer := range tx.transfers {
+		err := ctx.Err()
+		if err == nil {
+			log.Printf("[%s] Transferring: %s", transfer.directive, transfer.path)
+			err = tx.commitTransfer(transfer)
+		}
+
+		if err != nil {
+			log.Printf("Transfer failed; Rolling back client resources: %v", err)
+			rollbackErr := client.Rollback(tx.clientDirectory, tx.resources)
+			if rollbackErr != nil {
+				return errors.Join(err, fmt.Errorf("could not roll back client resources: %w", rollbackErr))
+			}
+
+			return err
+		}
+
+		notify(observer, Event{Kind: DirectiveApplied, Version: transfer.version, Directive: transfer.directive, Path: transfer.path})
+	}
+
+	return tx.resources.Journal().Clear()
+}
+
+// Removes the staging directory.
+func (tx *Transaction) Close() error {
+	return os.RemoveAll(tx.stageDir)
+}
+
+// Stages the transfers of each dependency, and then the patch, within a single transaction and then
+// commits the transaction.
+func transferAll(ctx context.Context, clientDirectory string, resources client.Resources, server Server, observer Observer, dependencies []Patch, patch Patch) error {
+	tx, err := newTransaction(clientDirectory, resources, server)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	stage := func(p Patch) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		stager, ok := p.(Stager)
+		if !ok {
+			return &PatchError{fmt.Errorf("%s: patch cannot be staged", p.Version())}
+		}
+
+		return stager.StageResources(ctx, tx, server)
+	}
+
+	for _, dependency := range dependencies {
+		notify(observer, Event{Kind: DependencyEntered, Version: dependency.Version()})
+
+		err := stage(dependency)
+		if err != nil {
+			return fmt.Errorf("transfer dependency: %w", err)
+		}
+	}
+
+	err = stage(patch)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx, observer)
+}
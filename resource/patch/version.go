@@ -3,13 +3,64 @@ package patch
 import (
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 var versionPattern = regexp.MustCompile(`^(v|V)?[0-9]+\.[0-9]+\.[0-9]+([0-9a-zA-Z_.-]+)?$`)
 
+var versionParts = regexp.MustCompile(`^(?:v|V)?([0-9]+)\.([0-9]+)\.([0-9]+)(.*)$`)
+
 func ValidateVersionName(version string) error {
 	if !versionPattern.MatchString(version) {
 		return fmt.Errorf("invalid version name \"%s\": version must match `%v`", version, versionPattern)
 	}
 	return nil
 }
+
+// Compares two version names by their major, minor, and patch numbers, and then by their
+// suffixes, where a version without a suffix is greater than the same version with a suffix.
+// The result is -1 if a < b, 0 if a == b, and +1 if a > b.
+//
+// Invalid version names are less than every valid version name, and are compared lexically.
+func CompareVersions(a, b string) int {
+	aValid, bValid := ValidateVersionName(a) == nil, ValidateVersionName(b) == nil
+	if !aValid || !bValid {
+		if aValid != bValid {
+			if aValid {
+				return 1
+			}
+			return -1
+		}
+
+		return strings.Compare(a, b)
+	}
+
+	aParts := versionParts.FindStringSubmatch(a)
+	bParts := versionParts.FindStringSubmatch(b)
+
+	for i := 1; i <= 3; i++ {
+		aNumber, _ := strconv.ParseUint(aParts[i], 10, 64)
+		bNumber, _ := strconv.ParseUint(bParts[i], 10, 64)
+
+		if aNumber < bNumber {
+			return -1
+		}
+
+		if aNumber > bNumber {
+			return 1
+		}
+	}
+
+	aSuffix, bSuffix := aParts[4], bParts[4]
+	switch {
+	case aSuffix == bSuffix:
+		return 0
+	case len(aSuffix) == 0:
+		return 1
+	case len(bSuffix) == 0:
+		return -1
+	}
+
+	return strings.Compare(aSuffix, bSuffix)
+}
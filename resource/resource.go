@@ -19,6 +19,7 @@ import (
 const (
 	settingsDir = "settings"
 	serversDir  = "servers"
+	patchesDir  = "patches"
 )
 
 const (
@@ -88,7 +89,8 @@ func LauncherSettings() (Settings, error) {
 		return Settings{}, fmt.Errorf("read launcher settings: %w", err)
 	}
 
-	settings := Settings{}
+	// Fields which are missing from launcher.json keep their default values
+	settings := DefaultSettings()
 	err = json.Unmarshal(data, &settings)
 	if err != nil {
 		return Settings{}, fmt.Errorf("unmarshal launcher settings: %w", err)
@@ -109,7 +111,7 @@ func ClientResources() (client.Resources, error) {
 
 func NewServer(config server.Config) *server.Server {
 	config.SettingsDir = settingsDir
-	config.DownloadDir = "patches"
+	config.DownloadDir = patchesDir
 	return server.New(config)
 }
 
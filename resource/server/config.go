@@ -10,5 +10,15 @@ type Config struct {
 	PatchToken    string
 	PatchProtocol string
 
+	// A base64 encoded Ed25519 public key. See Server.PatchPublicKey.
+	PatchPublicKey string
+
+	// See Server.PatchCA and Server.PatchFingerprint.
+	PatchCA          string
+	PatchFingerprint string
+
+	// See Server.PatchMirrors.
+	PatchMirrors []string
+
 	Config *ldf.BootConfig
 }
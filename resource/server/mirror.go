@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The mirror which most recently responded successfully for each server, mapped by server ID.
+var healthyMirrors = struct {
+	m   map[string]string
+	mux sync.Mutex
+}{
+	m: make(map[string]string),
+}
+
+// Returns an error if the mirror is not an absolute http or https URL.
+func ParseMirror(mirror string) (string, error) {
+	u, err := url.Parse(mirror)
+	if err != nil {
+		return "", fmt.Errorf("invalid mirror \"%s\": %w", mirror, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid mirror \"%s\": expected an http or https URL", mirror)
+	}
+
+	if len(u.Host) == 0 {
+		return "", fmt.Errorf("invalid mirror \"%s\": missing host", mirror)
+	}
+
+	return u.String(), nil
+}
+
+// Returns the base URLs of the server's Remote Patch Directory, starting with the patch server
+// configured by server.Config, followed by server.PatchMirrors, and then server.AdvertisedMirrors.
+// If the configured patch server does not form a valid URL, it is left out.
+//
+// Mirrors are not used with FileProtocol.
+func (server *Server) Mirrors() []string {
+	mirrors := []string{}
+	seen := make(map[string]bool)
+
+	if primary, err := server.PatchServerUrl(); err == nil {
+		mirrors = append(mirrors, primary)
+		seen[primary] = true
+	}
+
+	if server.UsesLocalPatches() {
+		return mirrors
+	}
+
+	for _, mirror := range append(append([]string{}, server.PatchMirrors...), server.AdvertisedMirrors...) {
+		mirror, err := ParseMirror(mirror)
+		if err != nil {
+			log.Printf("Skipping mirror for \"%s\": %v", server.Name, err)
+			continue
+		}
+
+		if !seen[mirror] {
+			seen[mirror] = true
+			mirrors = append(mirrors, mirror)
+		}
+	}
+
+	return mirrors
+}
+
+// Returns true if server.PatchToken may be sent to the mirror. The token is only sent to the configured
+// patch server and server.PatchMirrors, since server.AdvertisedMirrors are chosen by the summary.json. If
+// the server has a pinned public key, the summary.json is verified, so the token is also sent to its mirrors.
+func (server *Server) trustsMirror(mirror string) bool {
+	if len(server.PatchPublicKey) > 0 {
+		return true
+	}
+
+	if primary, err := server.PatchServerUrl(); err == nil && primary == mirror {
+		return true
+	}
+
+	for _, configured := range server.PatchMirrors {
+		if configured, err := ParseMirror(configured); err == nil && configured == mirror {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns server.Mirrors(), starting with the last mirror which responded successfully.
+func (server *Server) orderedMirrors() []string {
+	mirrors := server.Mirrors()
+
+	healthyMirrors.mux.Lock()
+	healthy, ok := healthyMirrors.m[server.ID]
+	healthyMirrors.mux.Unlock()
+
+	if !ok {
+		return mirrors
+	}
+
+	for i, mirror := range mirrors {
+		if mirror == healthy {
+			return append(append([]string{mirror}, mirrors[:i]...), mirrors[i+1:]...)
+		}
+	}
+
+	return mirrors
+}
+
+func (server *Server) setHealthyMirror(mirror string) {
+	healthyMirrors.mux.Lock()
+	defer healthyMirrors.mux.Unlock()
+
+	if previous, ok := healthyMirrors.m[server.ID]; ok && previous != mirror {
+		log.Printf("Using mirror \"%s\" for \"%s\"", mirror, server.Name)
+	}
+	healthyMirrors.m[server.ID] = mirror
+}
+
+// Returns true if another mirror should be tried after receiving the response.
+func mirrorFailed(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return response.StatusCode >= 500 && response.StatusCode != http.StatusServiceUnavailable
+}
+
+// The result of a single mirror's health check.
+type MirrorHealth struct {
+	Url     string
+	Latency time.Duration
+	Err     error
+}
+
+func (health MirrorHealth) Healthy() bool {
+	return health.Err == nil
+}
+
+// Sends a HEAD request for summary.json to each of the server's mirrors. The healthy mirror with the
+// lowest latency is used by later requests. The results are sorted with healthy mirrors first, in order
+// of their latency.
+func (server *Server) CheckMirrors(ctx context.Context) []MirrorHealth {
+	client, err := server.httpClient()
+	if err != nil {
+		return []MirrorHealth{}
+	}
+
+	mirrors := server.Mirrors()
+	results := make([]MirrorHealth, len(mirrors))
+
+	wg := sync.WaitGroup{}
+	for i, mirror := range mirrors {
+		wg.Add(1)
+		go func(i int, mirror string) {
+			defer wg.Done()
+
+			start := time.Now()
+			response, err := server.send(ctx, client, http.MethodHead, mirror, nil, "summary.json")
+			results[i] = MirrorHealth{Url: mirror, Latency: time.Since(start)}
+
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+			response.Body.Close()
+
+			if response.StatusCode >= 400 {
+				results[i].Err = fmt.Errorf("responded with %d", response.StatusCode)
+			}
+		}(i, mirror)
+	}
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Healthy() != results[j].Healthy() {
+			return results[i].Healthy()
+		}
+
+		return results[i].Healthy() && results[i].Latency < results[j].Latency
+	})
+
+	for _, result := range results {
+		if result.Healthy() {
+			log.Printf("Mirror \"%s\" is healthy (%v)", result.Url, result.Latency)
+		} else {
+			log.Printf("Mirror \"%s\" is unhealthy: %v", result.Url, result.Err)
+		}
+	}
+
+	if len(results) > 0 && results[0].Healthy() {
+		server.setHealthyMirror(results[0].Url)
+	}
+
+	return results
+}
+
+// Sends the request to each of the server's mirrors, starting with the last healthy mirror, until
+// a mirror responds without a network error or a 5xx status code. Requests are only retried by the
+// last mirror, so that a mirror which is down does not delay trying the next one.
+func (server *Server) sendToMirrors(ctx context.Context, client *http.Client, method string, header http.Header, elem ...string) (*http.Response, error) {
+	mirrors := server.orderedMirrors()
+
+	errs := []error{}
+	for i, mirror := range mirrors {
+		requestCtx := ctx
+		if i < len(mirrors)-1 {
+			requestCtx = withoutRetries(ctx)
+		}
+
+		response, err := server.send(requestCtx, client, method, mirror, header, elem...)
+		if ctx.Err() != nil || !mirrorFailed(response, err) {
+			if err == nil {
+				server.setHealthyMirror(mirror)
+			}
+
+			return response, err
+		}
+
+		if i == len(mirrors)-1 {
+			if err != nil {
+				return nil, errors.Join(append(errs, err)...)
+			}
+
+			return response, nil
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+			log.Printf("Mirror \"%s\" failed; Trying next mirror: %v", mirror, err)
+		} else {
+			log.Printf("Mirror \"%s\" responded with %d; Trying next mirror", mirror, response.StatusCode)
+			response.Body.Close()
+		}
+	}
+
+	return nil, errors.New("no patch server mirrors")
+}
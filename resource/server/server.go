@@ -1,8 +1,10 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -20,6 +22,9 @@ const (
 	HEADER_PATCH_TOKEN = "TPP-Token"
 )
 
+// The patch protocol which reads patches from the local directory, PatchServerDir, instead of from a patch server.
+const FileProtocol = "file"
+
 type State int
 
 const (
@@ -43,6 +48,31 @@ type Server struct {
 	PatchProtocol string `json:"patchProtocol"`
 	CurrentPatch  string `json:"currentPatch"`
 
+	// True if CurrentPatch was chosen from the patch history rather than being the server's current version.
+	// Pinned servers are only checked for updates when requested.
+	PatchPinned bool `json:"patchPinned,omitempty"`
+
+	// The patch runner advertised by the server's most recent summary.json. See patch.New
+	PatchRunner string `json:"patchRunner,omitempty"`
+
+	// A base64 encoded Ed25519 public key. If the key is not empty, the summary.json and every patch.json
+	// must be signed by the key's private key. See PATCHING.md
+	PatchPublicKey string `json:"patchPublicKey,omitempty"`
+
+	// PEM encoded certificates which are trusted for https patch servers, in addition to the system's roots.
+	PatchCA string `json:"patchCA,omitempty"`
+
+	// The SHA-256 fingerprint of the patch server's certificate. If the fingerprint is not empty, the patch
+	// server's certificate must match the fingerprint, even if the certificate is self-signed.
+	PatchFingerprint string `json:"patchFingerprint,omitempty"`
+
+	// Base URLs of Remote Patch Directories which serve the same patches as the server's patch server,
+	// e.g. "https://mirror.example.com/patches". See server.Mirrors
+	PatchMirrors []string `json:"patchMirrors,omitempty"`
+
+	// The mirrors advertised by the server's most recent summary.json.
+	AdvertisedMirrors []string `json:"advertisedMirrors,omitempty"`
+
 	Config *ldf.BootConfig `json:"-"`
 
 	hasPatchesList bool          `json:"-"`
@@ -59,6 +89,11 @@ func New(config Config) *Server {
 		PatchToken:    config.PatchToken,
 		PatchProtocol: config.PatchProtocol,
 		Config:        config.Config,
+
+		PatchPublicKey:   config.PatchPublicKey,
+		PatchCA:          config.PatchCA,
+		PatchFingerprint: config.PatchFingerprint,
+		PatchMirrors:     config.PatchMirrors,
 	}
 }
 
@@ -139,10 +174,31 @@ func (server *Server) DeleteConfig() error {
 }
 
 // Returns a string formatted as: server.PatchProtocol://PatchServerIP:PatchServerPort
+//
+// If server.PatchProtocol is FileProtocol, "file://" is returned.
 func (server *Server) PatchServerHost() string {
+	if server.UsesLocalPatches() {
+		return "file://"
+	}
+
 	return fmt.Sprint(server.PatchProtocol, "://", server.Config.PatchServerIP, ":", server.Config.PatchServerPort)
 }
 
+// Returns true if the server's patches are read from a local directory.
+func (server *Server) UsesLocalPatches() bool {
+	return server.PatchProtocol == FileProtocol
+}
+
+// Returns true if the server is configured to receive patches, i.e. the server has either a patch
+// server IP or, for FileProtocol, a local patch directory.
+func (server *Server) HasPatchServer() bool {
+	if server.UsesLocalPatches() {
+		return len(server.Config.PatchServerDir) > 0
+	}
+
+	return len(server.Config.PatchServerIP) > 0
+}
+
 // Calls url.JoinPath to format a valid URL in the form: server.PatchServerHost()/PatchServerDir/{elem...}
 //
 // For example:
@@ -151,7 +207,14 @@ func (server *Server) PatchServerHost() string {
 //	// PatchServerDir = "patches"
 //
 //	server.PatchServerUrl("some", "content", "here") // returns "http://127.0.0.1:10000/patches/some/content/here"
+//
+// If server.PatchProtocol is FileProtocol, PatchServerDir is the local directory itself, so the URL is in the
+// form: file:///{elem...}
 func (server *Server) PatchServerUrl(elem ...string) (string, error) {
+	if server.UsesLocalPatches() {
+		return url.JoinPath("file:///", elem...)
+	}
+
 	path := []string{server.Config.PatchServerDir}
 	return url.JoinPath(server.PatchServerHost(), append(path, elem...)...)
 }
@@ -162,31 +225,55 @@ func (server *Server) PatchServerUrl(elem ...string) (string, error) {
 // "server.DownloadDir()/{version}". If the file does not exist, the patch.json is
 // requested from the remote by calling server.RemoteGet(version, "patch.json").
 //
-// If server.RemoteGet returns an error, patch.ErrPatchesUnavailable is returned.
+// If server.RemoteGet returns an error, patch.ErrPatchesUnavailable is returned, unless ctx
+// is done, in which case the context's error is returned.
 //
 // If server.RemoteGet returns a status code of 401, patch.ErrPatchesUnauthorized is returned.
 //
 // If server.RemoteGet returns any other status code >= 400, patch.ErrPatchesUnavailable is returned.
 //
+// The patch is created by the runner registered as server.PatchRunner. If the runner is not registered,
+// an error wrapping patch.ErrUnsupportedProtocol is returned.
+//
+// If len(server.PatchPublicKey) > 0, the patch.json must have a valid signature located at "{version}/patch.json.sig".
+// A saved patch.json whose saved signature cannot be verified is requested from the remote again. Since the
+// signature does not cover the patch's resources, every download must also specify a sha256 hash, otherwise
+// an error wrapping patch.ErrChecksumMissing is returned.
+//
 // If the contents of the patch.json are formatted correctly (calling json.Marshal on the data does not return an error),
 // the data is saved in the file "server.DownloadDir()/{version}/patch.json".
-func (server *Server) GetPatch(version string) (patch.Patch, error) {
+func (server *Server) GetPatch(ctx context.Context, version string) (patch.Patch, error) {
 	patchDirectory := filepath.Join(server.DownloadDir(), version)
 	path := filepath.Join(patchDirectory, "patch.json")
 
 	data, err := os.ReadFile(path)
 	if err == nil {
-		patch := patch.NewTpp(version)
+		err = server.verifyLocal(data, path)
+	}
+
+	if err == nil {
+		patch, err := patch.New(server.PatchRunner, version)
+		if err != nil {
+			return nil, err
+		}
 
 		err = json.Unmarshal(data, &patch)
 		if err != nil {
 			return nil, fmt.Errorf("cannot unmarshal \"%s\": %w", path, err)
 		}
 
-		return patch, nil
+		return patch, server.requireChecksums(patch)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Could not verify saved patch.json for \"%s\": %v", version, err)
+	}
+
+	response, err := server.RemoteGet(ctx, version, "patch.json")
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
 
-	response, err := server.RemoteGet(version, "patch.json")
 	if err != nil {
 		return nil, patch.ErrPatchesUnavailable
 	}
@@ -205,12 +292,27 @@ func (server *Server) GetPatch(version string) (patch.Patch, error) {
 		return nil, fmt.Errorf("cannot read body of patch version response: %w", err)
 	}
 
-	patch := patch.NewTpp(version)
+	signature, err := server.verifyRemote(ctx, data, version, "patch.json")
+	if err != nil {
+		return nil, fmt.Errorf("patch.json for \"%s\": %w", version, err)
+	}
+	signaturePath := path + patch.SignatureSuffix
+
+	patch, err := patch.New(server.PatchRunner, version)
+	if err != nil {
+		return nil, err
+	}
+
 	err = json.Unmarshal(data, &patch)
 	if err != nil {
 		return nil, fmt.Errorf("malformed response body from patch version: %w", err)
 	}
 
+	err = server.requireChecksums(patch)
+	if err != nil {
+		return nil, err
+	}
+
 	os.MkdirAll(patchDirectory, 0755)
 
 	err = os.WriteFile(path, data, 0755)
@@ -218,44 +320,196 @@ func (server *Server) GetPatch(version string) (patch.Patch, error) {
 		log.Printf("Could not save patch.json: %v", err)
 	}
 
+	if signature != nil {
+		err = errors.Join(err, os.WriteFile(signaturePath, signature, 0755))
+	}
+
 	return patch, err
 }
 
+// Returns an error if a public key is pinned, and any of the patch's downloads do not specify a sha256 hash.
+func (server *Server) requireChecksums(p patch.Patch) error {
+	if len(server.PatchPublicKey) == 0 {
+		return nil
+	}
+
+	return patch.RequireChecksums(p)
+}
+
+// Verifies data against the signature saved at "{path}.sig".
+//
+// If len(server.PatchPublicKey) == 0, this method always returns nil.
+func (server *Server) verifyLocal(data []byte, path string) error {
+	if len(server.PatchPublicKey) == 0 {
+		return nil
+	}
+
+	signature, err := os.ReadFile(path + patch.SignatureSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return patch.ErrSignatureMissing
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return patch.VerifySignature(server.PatchPublicKey, data, signature)
+}
+
+// Requests the detached signature of the resource located by elem, and verifies it against data,
+// returning the signature.
+//
+// If len(server.PatchPublicKey) == 0, the signature is not requested, and this method returns nil, nil.
+//
+// If the remote responds with a status code of 404, patch.ErrSignatureMissing is returned.
+func (server *Server) verifyRemote(ctx context.Context, data []byte, elem ...string) ([]byte, error) {
+	if len(server.PatchPublicKey) == 0 || len(elem) == 0 {
+		return nil, nil
+	}
+
+	signatureElem := append([]string{}, elem...)
+	signatureElem[len(elem)-1] += patch.SignatureSuffix
+
+	response, err := server.RemoteGet(ctx, signatureElem...)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if err != nil {
+		return nil, patch.ErrPatchesUnavailable
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusUnauthorized {
+		return nil, patch.ErrPatchesUnauthorized
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, patch.ErrSignatureMissing
+	}
+
+	if response.StatusCode >= 400 {
+		return nil, patch.ErrPatchesUnavailable
+	}
+
+	signature, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read body of signature response: %w", err)
+	}
+
+	err = patch.VerifySignature(server.PatchPublicKey, data, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return signature, nil
+}
+
 // Returns an *http.Response after sending a request to the url created by server.PatchServerUrl(elem...).
 //
+// If the request fails with a network error, or a status code >= 500 other than 503, the request is sent to
+// each of the server's mirrors in turn, starting with the mirror which last responded successfully.
+//
 // If the len(server.PatchToken) > 0, the TPP-Token header is added to the request with the value of server.PatchToken.
-func (server *Server) RemoteGet(elem ...string) (*http.Response, error) {
-	url, err := server.PatchServerUrl(elem...)
+// The header is not sent to mirrors which are only advertised by an unverified summary.json.
+//
+// The request is cancelled once ctx is done.
+func (server *Server) RemoteGet(ctx context.Context, elem ...string) (*http.Response, error) {
+	return server.RemoteGetWithHeader(ctx, nil, elem...)
+}
+
+// Same as server.RemoteGet, but each of the values in header are added to the request.
+func (server *Server) RemoteGetWithHeader(ctx context.Context, header http.Header, elem ...string) (*http.Response, error) {
+	return server.remoteRequest(ctx, http.MethodGet, header, elem...)
+}
+
+// Same as server.RemoteGet, but sends a HEAD request, so the response has no body.
+func (server *Server) RemoteHead(ctx context.Context, elem ...string) (*http.Response, error) {
+	return server.remoteRequest(ctx, http.MethodHead, nil, elem...)
+}
+
+// Sends the request to each of the server's mirrors until one succeeds. See server.Mirrors.
+func (server *Server) remoteRequest(ctx context.Context, method string, header http.Header, elem ...string) (*http.Response, error) {
+	client, err := server.httpClient()
+	if err != nil {
+		return nil, fmt.Errorf("could not create patch server client: %w", err)
+	}
+
+	return server.sendToMirrors(ctx, client, method, header, elem...)
+}
+
+// Sends a request to the url created by joining the mirror's base URL with elem.
+func (server *Server) send(ctx context.Context, client *http.Client, method, mirror string, header http.Header, elem ...string) (*http.Response, error) {
+	url, err := url.JoinPath(mirror, elem...)
 	if err != nil {
 		return nil, fmt.Errorf("could not create patch url: %w", err)
 	}
 
-	log.Printf("Patch server request: %s", url)
-	request, err := http.NewRequest(http.MethodGet, url, nil)
+	log.Printf("Patch server request: %s %s", method, url)
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(server.PatchToken) > 0 {
+	for key, values := range header {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+
+	if len(server.PatchToken) > 0 && server.trustsMirror(mirror) {
 		request.Header.Set(HEADER_PATCH_TOKEN, server.PatchToken)
 	}
 
-	client := http.Client{}
 	return client.Do(request)
 }
 
 // Sends an HTTP request to the remote by calling server.RemoteGet("summary.json").
 //
-// If the request fails, patch.ErrPatchesUnavailable is returned.
+// If the request fails, patch.ErrPatchesUnavailable is returned, unless ctx is done, in which case
+// the context's error is returned.
 //
 // If the response returns a status code of 503, patch.ErrPatchesUnsupported is returned.
-func (server *Server) GetPatchesSummary() (patch.Summary, error) {
-	response, err := server.RemoteGet("summary.json")
+//
+// If len(server.PatchPublicKey) > 0, the summary.json must have a valid signature located at "summary.json.sig".
+//
+// If the summary.json advertises a runner which has not been registered, an error wrapping patch.ErrUnsupportedProtocol
+// is returned. Otherwise, the runner is saved as server.PatchRunner.
+//
+// The summary.json is saved to "server.DownloadDir()/summary.json". If a summary has been saved, the request includes
+// the If-None-Match and If-Modified-Since headers from the saved summary's ETag and Last-Modified headers, and a
+// response status code of 304 returns the saved summary. See server.SavedPatchesSummary.
+func (server *Server) GetPatchesSummary(ctx context.Context) (patch.Summary, error) {
+	header := http.Header{}
+
+	saved, validators, savedErr := server.readSavedSummary()
+	if savedErr == nil {
+		if len(validators.ETag) > 0 {
+			header.Set("If-None-Match", validators.ETag)
+		}
+
+		if len(validators.LastModified) > 0 {
+			header.Set("If-Modified-Since", validators.LastModified)
+		}
+	} else if !errors.Is(savedErr, os.ErrNotExist) {
+		log.Printf("Could not use saved summary.json for \"%s\": %v", server.Name, savedErr)
+	}
+
+	response, err := server.RemoteGetWithHeader(ctx, header, "summary.json")
+	if ctx.Err() != nil {
+		return patch.Summary{}, ctx.Err()
+	}
+
 	if err != nil {
 		return patch.Summary{}, patch.ErrPatchesUnavailable
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode == http.StatusNotModified && savedErr == nil {
+		log.Printf("summary.json for \"%s\" has not been modified", server.Name)
+		return server.useSummary(saved)
+	}
+
 	if response.StatusCode == http.StatusServiceUnavailable {
 		return patch.Summary{}, patch.ErrPatchesUnsupported
 	}
@@ -269,15 +523,35 @@ func (server *Server) GetPatchesSummary() (patch.Summary, error) {
 		return patch.Summary{}, fmt.Errorf("cannot read body of patch server response: %w", err)
 	}
 
+	signature, err := server.verifyRemote(ctx, data, "summary.json")
+	if err != nil {
+		return patch.Summary{}, fmt.Errorf("summary.json: %w", err)
+	}
+
 	patches := patch.Summary{}
 	err = json.Unmarshal(data, &patches)
 	if err != nil {
 		return patch.Summary{}, fmt.Errorf("malformed response body from server: %w", err)
 	}
 
+	patches, err = server.useSummary(patches)
+	if err != nil {
+		return patch.Summary{}, err
+	}
+
+	err = server.saveSummary(data, signature, response.Header)
+	if err != nil {
+		log.Printf("Could not save summary.json: %v", err)
+	}
+
 	return patches, nil
 }
 
+// Returns server.Config.
+func (server *Server) BootConfig() *ldf.BootConfig {
+	return server.Config
+}
+
 // Sets server.Config to boot and then calls server.SaveConfig() returning the error.
 func (server *Server) SetBootConfig(boot *ldf.BootConfig) error {
 	server.Config = boot
@@ -298,12 +572,20 @@ func (server *Server) ToXML() XML {
 			Text: string(data),
 		},
 		Patch: struct {
-			XMLName  xml.Name `xml:"patch"`
-			Token    string   `xml:"token"`
-			Protocol string   `xml:"protocol"`
+			XMLName     xml.Name `xml:"patch"`
+			Token       string   `xml:"token"`
+			Protocol    string   `xml:"protocol"`
+			PublicKey   string   `xml:"publicKey"`
+			CA          string   `xml:"ca,omitempty"`
+			Fingerprint string   `xml:"fingerprint,omitempty"`
+			Mirrors     []string `xml:"mirrors>mirror,omitempty"`
 		}{
-			Token:    server.PatchToken,
-			Protocol: server.PatchProtocol,
+			Token:       server.PatchToken,
+			Protocol:    server.PatchProtocol,
+			PublicKey:   server.PatchPublicKey,
+			CA:          server.PatchCA,
+			Fingerprint: server.PatchFingerprint,
+			Mirrors:     server.PatchMirrors,
 		},
 	}
 }
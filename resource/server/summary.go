@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+)
+
+// The validators of a saved summary.json, which are sent with later requests so that the patch
+// server can respond with 304 Not Modified if the summary has not changed.
+type summaryValidators struct {
+	// The URL the summary was requested from. A saved summary is ignored once the server's
+	// patch server changes.
+	Url string `json:"url"`
+
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// Returns the path in the format: {server.DownloadDir()}/summary.json
+func (server *Server) summaryPath() string {
+	return filepath.Join(server.DownloadDir(), "summary.json")
+}
+
+func (server *Server) summaryValidatorsPath() string {
+	return server.summaryPath() + ".validators"
+}
+
+// Reads the summary.json saved by server.saveSummary, along with its validators.
+//
+// If len(server.PatchPublicKey) > 0, the saved summary.json must have a valid saved signature.
+func (server *Server) readSavedSummary() (patch.Summary, summaryValidators, error) {
+	path := server.summaryPath()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return patch.Summary{}, summaryValidators{}, err
+	}
+
+	err = server.verifyLocal(data, path)
+	if err != nil {
+		return patch.Summary{}, summaryValidators{}, err
+	}
+
+	validators := summaryValidators{}
+
+	validatorsData, err := os.ReadFile(server.summaryValidatorsPath())
+	if err != nil {
+		return patch.Summary{}, summaryValidators{}, err
+	}
+
+	err = json.Unmarshal(validatorsData, &validators)
+	if err != nil {
+		return patch.Summary{}, summaryValidators{}, fmt.Errorf("cannot unmarshal \"%s\": %w", server.summaryValidatorsPath(), err)
+	}
+
+	url, err := server.PatchServerUrl("summary.json")
+	if err != nil || url != validators.Url {
+		return patch.Summary{}, summaryValidators{}, errors.New("summary.json was saved from a different patch server")
+	}
+
+	summary := patch.Summary{}
+	err = json.Unmarshal(data, &summary)
+	if err != nil {
+		return patch.Summary{}, summaryValidators{}, fmt.Errorf("cannot unmarshal \"%s\": %w", path, err)
+	}
+
+	return summary, validators, nil
+}
+
+// Saves the summary.json data, its signature, and the validators from the response header.
+func (server *Server) saveSummary(data, signature []byte, header http.Header) error {
+	url, err := server.PatchServerUrl("summary.json")
+	if err != nil {
+		return err
+	}
+
+	validators, err := json.Marshal(summaryValidators{
+		Url:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(server.DownloadDir(), 0755)
+	if err != nil {
+		return err
+	}
+
+	path := server.summaryPath()
+
+	err = os.WriteFile(path, data, 0755)
+	if err != nil {
+		return err
+	}
+
+	if signature != nil {
+		err = os.WriteFile(path+patch.SignatureSuffix, signature, 0755)
+	} else {
+		err = os.Remove(path + patch.SignatureSuffix)
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(server.summaryValidatorsPath(), validators, 0755)
+}
+
+// Returns the summary.json saved by the last successful call to server.GetPatchesSummary, which
+// allows the last known patch status to be shown while the patch server cannot be reached.
+//
+// If no summary has been saved, or the saved summary cannot be verified, this method returns false.
+func (server *Server) SavedPatchesSummary() (patch.Summary, bool) {
+	summary, _, err := server.readSavedSummary()
+	if err != nil {
+		return patch.Summary{}, false
+	}
+
+	return summary, true
+}
+
+// Checks that the summary's runner is supported, and saves it as server.PatchRunner. The summary's
+// mirrors are saved as server.AdvertisedMirrors.
+func (server *Server) useSummary(summary patch.Summary) (patch.Summary, error) {
+	if !patch.Supports(summary.Runner) {
+		return patch.Summary{}, fmt.Errorf("%w \"%s\"; supported protocols: %s", patch.ErrUnsupportedProtocol, summary.Runner, strings.Join(patch.Runners(), ", "))
+	}
+	server.PatchRunner = summary.Runner
+	server.AdvertisedMirrors = summary.Mirrors
+
+	return summary, nil
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings for the HTTP transport shared by every server's patch server requests.
+type TransportConfig struct {
+	// The maximum amount of time spent connecting to a patch server, including the TLS handshake.
+	ConnectTimeout time.Duration
+
+	// The maximum amount of time spent waiting for a patch server's response headers once a
+	// request has been sent. The response body is not limited, so large downloads are not cut off.
+	ResponseTimeout time.Duration
+
+	// The number of times GET and HEAD requests are retried after a network error or a 5xx response.
+	Retries int
+
+	// The delay before the first retry. The delay doubles after each retry.
+	RetryDelay time.Duration
+
+	// The URL of the proxy which requests are sent through. If Proxy is empty, the proxy is read
+	// from the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables.
+	Proxy string
+}
+
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		ConnectTimeout:  10 * time.Second,
+		ResponseTimeout: 30 * time.Second,
+		Retries:         3,
+		RetryDelay:      500 * time.Millisecond,
+	}
+}
+
+var transports = struct {
+	config TransportConfig
+	cache  map[string]*http.Transport
+	mux    sync.Mutex
+}{
+	config: DefaultTransportConfig(),
+	cache:  make(map[string]*http.Transport),
+}
+
+// Sets the configuration used by every server's transport. Idle connections of the previous
+// transports are closed.
+func SetTransportConfig(config TransportConfig) error {
+	if len(config.Proxy) > 0 {
+		if _, err := url.Parse(config.Proxy); err != nil {
+			return fmt.Errorf("invalid proxy url: %w", err)
+		}
+	}
+
+	transports.mux.Lock()
+	defer transports.mux.Unlock()
+
+	for _, transport := range transports.cache {
+		transport.CloseIdleConnections()
+	}
+
+	transports.config = config
+	transports.cache = make(map[string]*http.Transport)
+	return nil
+}
+
+func GetTransportConfig() TransportConfig {
+	transports.mux.Lock()
+	defer transports.mux.Unlock()
+	return transports.config
+}
+
+// Returns the SHA-256 fingerprint of the certificate as a lowercase hex string.
+func CertificateFingerprint(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Normalizes a SHA-256 certificate fingerprint, which may be separated by colons, e.g., "AB:CD:...",
+// into a lowercase hex string.
+func ParseFingerprint(fingerprint string) (string, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+
+	data, err := hex.DecodeString(normalized)
+	if err != nil || len(data) != sha256.Size {
+		return "", fmt.Errorf("invalid certificate fingerprint: expected a hex encoded SHA-256 hash")
+	}
+
+	return normalized, nil
+}
+
+// Returns a certificate pool containing the PEM encoded certificates.
+func ParseCertificateAuthority(data string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(data)) {
+		return nil, errors.New("invalid certificate authority: no PEM encoded certificates")
+	}
+
+	return pool, nil
+}
+
+func proxyFunc(proxy string) func(*http.Request) (*url.URL, error) {
+	if len(proxy) == 0 {
+		return http.ProxyFromEnvironment
+	}
+
+	proxyUrl, err := url.Parse(proxy)
+	if err != nil {
+		return http.ProxyFromEnvironment
+	}
+
+	return http.ProxyURL(proxyUrl)
+}
+
+// Returns a TLS configuration which trusts the server's PatchCA in addition to the system's roots, and,
+// if the server has a PatchFingerprint, only accepts a certificate with the same fingerprint.
+func (server *Server) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if len(server.PatchCA) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM([]byte(server.PatchCA)) {
+			return nil, errors.New("invalid certificate authority: no PEM encoded certificates")
+		}
+
+		config.RootCAs = pool
+	}
+
+	if len(server.PatchFingerprint) > 0 {
+		expected, err := ParseFingerprint(server.PatchFingerprint)
+		if err != nil {
+			return nil, err
+		}
+
+		// The pinned certificate replaces the usual chain verification, which allows self-signed certificates
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("patch server did not send a certificate")
+			}
+
+			actual := CertificateFingerprint(state.PeerCertificates[0])
+			if actual != expected {
+				return fmt.Errorf("%w: got %s", errFingerprintMismatch, actual)
+			}
+
+			return nil
+		}
+	}
+
+	return config, nil
+}
+
+// Returns the transport for the server, which is shared with every server that has the same PatchCA
+// and PatchFingerprint.
+func (server *Server) transport() (*http.Transport, TransportConfig, error) {
+	transports.mux.Lock()
+	defer transports.mux.Unlock()
+
+	config := transports.config
+
+	key := server.PatchCA + "\x00" + server.PatchFingerprint
+	if transport, ok := transports.cache[key]; ok {
+		return transport, config, nil
+	}
+
+	tlsConfig, err := server.tlsConfig()
+	if err != nil {
+		return nil, config, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(config.Proxy)
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = config.ConnectTimeout
+	transport.ResponseHeaderTimeout = config.ResponseTimeout
+	transport.TLSClientConfig = tlsConfig
+
+	transports.cache[key] = transport
+	return transport, config, nil
+}
+
+var errFingerprintMismatch = errors.New("patch server certificate does not match the pinned fingerprint")
+
+// Returns true if the request should be retried after receiving the response. A 503 is not
+// retried, since TPP servers use it to signal that patches are unsupported, and certificates
+// which cannot be verified are not retried, since they will not become valid.
+func shouldRetry(response *http.Response, err error) bool {
+	if err != nil {
+		var verificationErr *tls.CertificateVerificationError
+		return !errors.As(err, &verificationErr) && !errors.Is(err, errFingerprintMismatch)
+	}
+
+	switch response.StatusCode {
+	case http.StatusNotImplemented, http.StatusServiceUnavailable:
+		return false
+	}
+
+	return response.StatusCode >= 500
+}
+
+type noRetriesKey struct{}
+
+// Returns a context whose requests are not retried by retryTransport.
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetriesKey{}, true)
+}
+
+// Retries idempotent requests with an exponential backoff, unless the request's context was
+// created by withoutRetries.
+type retryTransport struct {
+	http.RoundTripper
+
+	retries int
+	delay   time.Duration
+}
+
+func (transport retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return transport.RoundTripper.RoundTrip(request)
+	}
+
+	retries := transport.retries
+	if request.Context().Value(noRetriesKey{}) != nil {
+		retries = 0
+	}
+
+	delay := transport.delay
+	for attempt := 0; ; attempt++ {
+		response, err := transport.RoundTripper.RoundTrip(request)
+		if attempt >= retries || request.Context().Err() != nil || !shouldRetry(response, err) {
+			return response, err
+		}
+
+		if err != nil {
+			log.Printf("Patch server request failed; Retrying in %v: %v", delay, err)
+		} else {
+			log.Printf("Patch server responded with %d; Retrying in %v", response.StatusCode, delay)
+			response.Body.Close()
+		}
+
+		select {
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
+
+// Returns the client used for the server's requests.
+func (server *Server) httpClient() (*http.Client, error) {
+	if server.UsesLocalPatches() {
+		return &http.Client{Transport: http.NewFileTransport(http.Dir(server.Config.PatchServerDir))}, nil
+	}
+
+	transport, config, err := server.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: retryTransport{
+			RoundTripper: transport,
+			retries:      config.Retries,
+			delay:        config.RetryDelay,
+		},
+	}, nil
+}
@@ -10,9 +10,13 @@ type XML struct {
 	XMLName xml.Name `xml:"server"`
 	Name    string   `xml:"name"`
 	Patch   struct {
-		XMLName  xml.Name `xml:"patch"`
-		Token    string   `xml:"token"`
-		Protocol string   `xml:"protocol"`
+		XMLName     xml.Name `xml:"patch"`
+		Token       string   `xml:"token"`
+		Protocol    string   `xml:"protocol"`
+		PublicKey   string   `xml:"publicKey"`
+		CA          string   `xml:"ca,omitempty"`
+		Fingerprint string   `xml:"fingerprint,omitempty"`
+		Mirrors     []string `xml:"mirrors>mirror,omitempty"`
 	} `xml:"patch"`
 	Boot struct {
 		Text string `xml:",innerxml"`
@@ -44,7 +44,7 @@ func (list *ServerList) Load() error {
 	}
 
 	for _, server := range list.list {
-		err := server.LoadConfig(settingsDir, "patches")
+		err := server.LoadConfig(settingsDir, patchesDir)
 		if err != nil {
 			log.Printf("load servers error: %v", err)
 		}
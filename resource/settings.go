@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
 )
 
 const (
@@ -26,6 +30,15 @@ type Settings struct {
 	CloseOnPlay               bool `json:"closeOnPlay"`
 	CheckPatchesAutomatically bool `json:"checkPatchesAutomatically"`
 	ReviewPatchBeforeUpdate   bool `json:"reviewPatchBeforeUpdate"`
+	MaxConcurrentDownloads    int  `json:"maxConcurrentDownloads"`
+
+	// See server.TransportConfig
+	Network struct {
+		ConnectTimeout  int    `json:"connectTimeout"`  // Seconds
+		ResponseTimeout int    `json:"responseTimeout"` // Seconds
+		Retries         int    `json:"retries"`
+		Proxy           string `json:"proxy"`
+	} `json:"network"`
 }
 
 func (settings *Settings) Adjust() {
@@ -39,6 +52,34 @@ func (settings *Settings) Adjust() {
 	if len(settings.Client.Name) == 0 {
 		settings.Client.Name = DEFAULT_EXE_CLIENT
 	}
+
+	if settings.MaxConcurrentDownloads <= 0 {
+		settings.MaxConcurrentDownloads = patch.DefaultConcurrentDownloads
+	}
+
+	defaultTransport := server.DefaultTransportConfig()
+
+	if settings.Network.ConnectTimeout <= 0 {
+		settings.Network.ConnectTimeout = int(defaultTransport.ConnectTimeout.Seconds())
+	}
+
+	if settings.Network.ResponseTimeout <= 0 {
+		settings.Network.ResponseTimeout = int(defaultTransport.ResponseTimeout.Seconds())
+	}
+
+	if settings.Network.Retries < 0 {
+		settings.Network.Retries = 0
+	}
+}
+
+// Returns the transport configuration described by settings.Network.
+func (settings *Settings) TransportConfig() server.TransportConfig {
+	config := server.DefaultTransportConfig()
+	config.ConnectTimeout = time.Duration(settings.Network.ConnectTimeout) * time.Second
+	config.ResponseTimeout = time.Duration(settings.Network.ResponseTimeout) * time.Second
+	config.Retries = settings.Network.Retries
+	config.Proxy = settings.Network.Proxy
+	return config
 }
 
 func (settings *Settings) ClientPath() string {
@@ -65,6 +106,7 @@ func DefaultSettings() Settings {
 	s.Client.Directory = "%{DEFAULTPATH}%"
 	s.CloseOnPlay = true
 	s.ReviewPatchBeforeUpdate = true
+	s.Network.Retries = server.DefaultTransportConfig().Retries
 
 	s.Adjust()
 	return s
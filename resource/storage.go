@@ -0,0 +1,172 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
+)
+
+// The contents of the patches directory, and the rejected patches, which are no longer needed
+// by any server.
+type Garbage struct {
+	// Download directories of servers which no longer exist.
+	OrphanedServers []string
+
+	// Version directories which are neither a server's current patch, nor one of the current
+	// patch's dependencies.
+	StaleVersions []string
+
+	// IDs of servers which no longer exist, but still have rejected patches.
+	OrphanedRejections []string
+
+	// The total size, in bytes, of OrphanedServers and StaleVersions.
+	Size int64
+}
+
+// Returns true if there is nothing to remove.
+func (garbage Garbage) Empty() bool {
+	return len(garbage.OrphanedServers) == 0 && len(garbage.StaleVersions) == 0 && len(garbage.OrphanedRejections) == 0
+}
+
+func directorySize(dir string) int64 {
+	size := int64(0)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+	return size
+}
+
+// Returns the versions which must be kept for the server: its current patch, and every resolved
+// dependency of the current patch. If the dependencies cannot be resolved, ok is false, and none
+// of the server's versions should be removed.
+func keptVersions(ctx context.Context, serv *server.Server) (map[string]bool, bool) {
+	kept := make(map[string]bool)
+	if len(serv.CurrentPatch) == 0 {
+		return kept, true
+	}
+
+	kept[serv.CurrentPatch] = true
+
+	current, err := serv.GetPatch(ctx, serv.CurrentPatch)
+	if err != nil {
+		log.Printf("Could not get current patch for \"%s\": %v", serv.Name, err)
+		return kept, false
+	}
+
+	dependencies, err := patch.ResolveDependencies(ctx, serv, current)
+	if err != nil {
+		log.Printf("Could not resolve dependencies for \"%s\": %v", serv.Name, err)
+		return kept, false
+	}
+
+	for _, dependency := range dependencies {
+		kept[dependency.Version()] = true
+	}
+
+	return kept, true
+}
+
+// Finds the contents of the patches directory which are not needed by any of the servers, and the
+// rejected patches of servers which no longer exist. Nothing is removed.
+//
+// The current patch of each server, and the patch's dependencies, are read from the patches directory
+// if they have been saved, and otherwise are requested from the server's patch server.
+func FindGarbage(ctx context.Context, servers *ServerList, rejections *patch.RejectionList) (Garbage, error) {
+	garbage := Garbage{
+		OrphanedServers:    []string{},
+		StaleVersions:      []string{},
+		OrphanedRejections: []string{},
+	}
+
+	if rejections != nil {
+		for _, id := range rejections.ServerIds() {
+			if servers.Get(id) == nil {
+				garbage.OrphanedRejections = append(garbage.OrphanedRejections, id)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(patchesDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return garbage, nil
+	}
+
+	if err != nil {
+		return garbage, fmt.Errorf("cannot read patches directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return garbage, err
+		}
+
+		path := filepath.Join(patchesDir, entry.Name())
+
+		serv := servers.Get(entry.Name())
+		if serv == nil {
+			garbage.OrphanedServers = append(garbage.OrphanedServers, path)
+			garbage.Size += directorySize(path)
+			continue
+		}
+
+		kept, ok := keptVersions(ctx, serv)
+		if !ok {
+			continue
+		}
+
+		versions, err := os.ReadDir(path)
+		if err != nil {
+			return garbage, fmt.Errorf("cannot read download directory for \"%s\": %w", serv.Name, err)
+		}
+
+		for _, version := range versions {
+			// Files within the download directory, e.g. the saved summary.json, belong to the server itself
+			if !version.IsDir() || kept[version.Name()] {
+				continue
+			}
+
+			versionPath := filepath.Join(path, version.Name())
+			garbage.StaleVersions = append(garbage.StaleVersions, versionPath)
+			garbage.Size += directorySize(versionPath)
+		}
+	}
+
+	return garbage, nil
+}
+
+// Removes everything found by FindGarbage.
+func CollectGarbage(garbage Garbage, rejections *patch.RejectionList) error {
+	errs := []error{}
+
+	for _, path := range append(garbage.OrphanedServers, garbage.StaleVersions...) {
+		log.Printf("Removing \"%s\"", path)
+		err := os.RemoveAll(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot remove \"%s\": %w", path, err))
+		}
+	}
+
+	if rejections != nil {
+		err := rejections.Forget(garbage.OrphanedRejections...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,165 @@
+package resource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/I-Am-Dench/nimbus-launcher/resource/patch"
+	"github.com/I-Am-Dench/nimbus-launcher/resource/server"
+)
+
+// Changes the working directory to a temporary directory for the duration of the test, since the
+// patches directory is relative to the working directory.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+
+	return dir
+}
+
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	for relativePath, data := range files {
+		path := filepath.FromSlash(relativePath)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("write files: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(data), 0755); err != nil {
+			t.Fatalf("write files: %v", err)
+		}
+	}
+}
+
+func testServer(id, currentPatch string) *server.Server {
+	serv := server.New(server.Config{
+		SettingsDir: settingsDir,
+		DownloadDir: patchesDir,
+		Name:        id,
+	})
+	serv.ID = id
+	serv.CurrentPatch = currentPatch
+
+	return serv
+}
+
+func sorted(paths []string) string {
+	paths = append([]string{}, paths...)
+	sort.Strings(paths)
+	return strings.Join(paths, " ")
+}
+
+func TestGarbage(t *testing.T) {
+	chdirTemp(t)
+
+	writeFiles(t, map[string]string{
+		// v3.0.0 recursively depends on v2.0.0, so v2.0.0's dependency on v1.0.0 must also be kept,
+		// but v1.0.0's own dependency on v0.5.0 is not
+		"patches/current/v3.0.0/patch.json": `{"depend": ["v2.0.0*"]}`,
+		"patches/current/v2.0.0/patch.json": `{"depend": ["v1.0.0"]}`,
+		"patches/current/v1.0.0/patch.json": `{"depend": ["v0.5.0"]}`,
+		"patches/current/v0.5.0/patch.json": `{}`,
+		"patches/current/v0.1.0/patch.json": `{}`,
+		"patches/current/summary.json":      `{}`,
+
+		"patches/unpatched/v1.0.0/patch.json": `{}`,
+
+		"patches/removed/v1.0.0/patch.json":  `{}`,
+		"patches/removed/v1.0.0/resources/a": "removed server data",
+	})
+
+	servers := &ServerList{
+		list: []*server.Server{
+			testServer("current", "v3.0.0"),
+			testServer("unpatched", ""),
+		},
+	}
+
+	rejections := patch.NewRejectionList(filepath.Join(settingsDir, "rejected_patches.json"))
+	if err := os.MkdirAll(settingsDir, 0755); err != nil {
+		t.Fatalf("test garbage: %v", err)
+	}
+
+	rejections.Add(servers.Get("current"), "v4.0.0")
+	rejections.Add(testServer("removed", ""), "v2.0.0")
+
+	garbage, err := FindGarbage(context.Background(), servers, rejections)
+	if err != nil {
+		t.Fatalf("test garbage: %v", err)
+	}
+
+	expectedStale := sorted([]string{
+		filepath.Join("patches", "current", "v0.1.0"),
+		filepath.Join("patches", "current", "v0.5.0"),
+		filepath.Join("patches", "unpatched", "v1.0.0"),
+	})
+	if stale := sorted(garbage.StaleVersions); stale != expectedStale {
+		t.Errorf("test garbage: expected stale versions \"%s\" but got \"%s\"", expectedStale, stale)
+	}
+
+	expectedOrphaned := filepath.Join("patches", "removed")
+	if orphaned := sorted(garbage.OrphanedServers); orphaned != expectedOrphaned {
+		t.Errorf("test garbage: expected orphaned servers \"%s\" but got \"%s\"", expectedOrphaned, orphaned)
+	}
+
+	if orphaned := sorted(garbage.OrphanedRejections); orphaned != "removed" {
+		t.Errorf("test garbage: expected orphaned rejections \"removed\" but got \"%s\"", orphaned)
+	}
+
+	if garbage.Size == 0 {
+		t.Errorf("test garbage: expected a non-zero size")
+	}
+
+	if err := CollectGarbage(garbage, rejections); err != nil {
+		t.Fatalf("test garbage: collect: %v", err)
+	}
+
+	for _, path := range append(garbage.StaleVersions, garbage.OrphanedServers...) {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("test garbage: expected \"%s\" to be removed", path)
+		}
+	}
+
+	for _, version := range []string{"v3.0.0", "v2.0.0", "v1.0.0"} {
+		if _, err := os.Stat(filepath.Join("patches", "current", version, "patch.json")); err != nil {
+			t.Errorf("test garbage: expected \"%s\" to be kept: %v", version, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join("patches", "current", "summary.json")); err != nil {
+		t.Errorf("test garbage: expected the summary.json to be kept: %v", err)
+	}
+
+	if ids := sorted(rejections.ServerIds()); ids != "current" {
+		t.Errorf("test garbage: expected only the rejections of \"current\" to remain but got \"%s\"", ids)
+	}
+
+	garbage, err = FindGarbage(context.Background(), servers, rejections)
+	if err != nil {
+		t.Fatalf("test garbage: rescan: %v", err)
+	}
+
+	if !garbage.Empty() {
+		t.Errorf("test garbage: expected nothing to remain after collecting but got %+v", garbage)
+	}
+}